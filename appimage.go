@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/otiai10/copy"
+)
+
+// appImageArchs maps GOARCH to AppImage architectures
+var appImageArchs = map[string]string{
+	"amd64": "x86_64",
+	"386":   "i686",
+	"arm64": "aarch64",
+	"arm":   "armhf",
+}
+
+const appRunTemplate = `#!/bin/sh
+# AppRun generated by tge-cli, assets are loaded from the working directory
+HERE="$(dirname "$(readlink -f "$0")")"
+cd "$HERE/usr/share/%[1]s" || exit 1
+exec "$HERE/usr/bin/%[1]s" "$@"
+`
+
+// packageAppImage writes an AppDir of the Linux application in dist, the
+// AppImage is generated from it when appimagetool is available.
+func (builder *Builder) packageAppImage(binaryFile string) error {
+	metadata := builder.packageMetadata()
+	appDirPath := filepath.Join(builder.distPath, metadata.name+".AppDir")
+	log("NOTICE", fmt.Sprintf("Generating AppDir: %s", appDirPath))
+	if err := os.RemoveAll(appDirPath); err != nil {
+		return err
+	}
+	binPath := filepath.Join(appDirPath, "usr", "bin")
+	sharePath := filepath.Join(appDirPath, "usr", "share", metadata.name)
+	for _, dir := range []string{binPath, sharePath} {
+		if err := os.MkdirAll(dir, os.ModeDir|0755); err != nil {
+			return err
+		}
+	}
+
+	// Binary & assets
+	binary, err := ioutil.ReadFile(filepath.Join(builder.distPath, binaryFile))
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(binPath, metadata.name), binary, 0755); err != nil {
+		return err
+	}
+	if err = copy.Copy(builder.assetsPath, filepath.Join(sharePath, assetsPath)); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(appDirPath, "AppRun"), []byte(fmt.Sprintf(appRunTemplate, metadata.name)), 0755); err != nil {
+		return err
+	}
+
+	// Desktop entry, a custom one can be set in the linux folder
+	entry := metadata.desktopEntry(metadata.name, "", builder.profile.console)
+	customPath := filepath.Join(builder.packagePath, builder.target, metadata.name+".desktop")
+	content, custom := readOptionalFile(customPath)
+	if custom {
+		entry = string(content)
+	}
+	if err = validateDesktopEntry(entry); err != nil {
+		if custom {
+			return newError(errProject, err, "invalid desktop entry %s", customPath)
+		}
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(appDirPath, metadata.name+".desktop"), []byte(entry), 0644); err != nil {
+		return err
+	}
+
+	// Icons
+	if iconPath := builder.packageIcon(); iconPath != "" {
+		for _, size := range hicolorIconSizes {
+			iconDir := filepath.Join(appDirPath, "usr", "share", "icons", "hicolor", fmt.Sprintf("%dx%d", size, size), "apps")
+			if err = os.MkdirAll(iconDir, os.ModeDir|0755); err != nil {
+				return err
+			}
+			if err = resizePNG(iconPath, filepath.Join(iconDir, metadata.name+".png"), size); err != nil {
+				return fmt.Errorf("failed to generate icon: %s", err)
+			}
+		}
+		size := hicolorIconSizes[len(hicolorIconSizes)-1]
+		if err = resizePNG(iconPath, filepath.Join(appDirPath, metadata.name+".png"), size); err != nil {
+			return fmt.Errorf("failed to generate icon: %s", err)
+		}
+		os.Remove(filepath.Join(appDirPath, ".DirIcon"))
+		if err = os.Symlink(metadata.name+".png", filepath.Join(appDirPath, ".DirIcon")); err != nil {
+			return err
+		}
+	}
+
+	// AppImage
+	appimagetoolbin := builder.config.get("tools.appimagetool")
+	if appimagetoolbin == "" {
+		if appimagetoolbin, err = exec.LookPath("appimagetool"); err != nil {
+			log("NOTICE", "appimagetool not found in PATH, only the AppDir is generated (see https://appimage.github.io/appimagetool/)")
+			return nil
+		}
+	}
+	arch, found := appImageArchs[goArch()]
+	if !found {
+		return newError(errUsage, nil, "unsupported architecture '%s' for AppImage", goArch())
+	}
+	appImageFile := fmt.Sprintf("%s-%s-%s.AppImage", metadata.name, metadata.version, arch)
+	log("NOTICE", fmt.Sprintf("Packaging %s", appImageFile))
+	cmd := exec.Command(appimagetoolbin, appDirPath, filepath.Join(builder.distPath, appImageFile))
+	cmd.Env = append(builder.environ(), "ARCH="+arch)
+	if err := runCommand(cmd); err != nil {
+		return newError(errPackaging, err, "appimagetool failed")
+	}
+	return nil
+}
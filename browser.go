@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const browserPageFile = "index.html"
+const wasmFile = "main.wasm"
+const wasmExecFile = "wasm_exec.js"
+
+// wasmExecPaths lists the folders of wasm_exec.js in GOROOT, lib/wasm since
+// Go 1.24 and misc/wasm before.
+var wasmExecPaths = []string{filepath.Join("lib", "wasm"), filepath.Join("misc", "wasm")}
+
+// tinygoWasmExecPaths lists the folders of wasm_exec.js in TINYGOROOT
+var tinygoWasmExecPaths = []string{"targets"}
+
+// BrowserSettings holds the manifest settings of the browser page
+type BrowserSettings struct {
+	// Title is the page title, default is the application name
+	Title string `json:"title,omitempty"`
+	// Width and Height set the canvas size in pixels, the canvas fills the
+	// window if not set
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// Loading shows a loading screen until the application is started,
+	// default is true
+	Loading *bool `json:"loading,omitempty"`
+	// SingleFile generates a self-contained HTML file, as -single-file
+	SingleFile bool `json:"singleFile,omitempty"`
+	// SingleFileMaxSize is the size of the single HTML file above which a
+	// warning is logged, default is 20MB
+	SingleFileMaxSize string `json:"singleFileMaxSize,omitempty"`
+	// PWA generates a web app manifest and a service worker, as -pwa
+	PWA bool `json:"pwa,omitempty"`
+	// ShortName is the PWA name on home screens, default is the title
+	ShortName string `json:"shortName,omitempty"`
+	// Display is the PWA display mode, default is fullscreen
+	Display string `json:"display,omitempty"`
+	// Orientation is the PWA orientation (any, landscape, portrait...),
+	// default is any
+	Orientation string `json:"orientation,omitempty"`
+	// ThemeColor and BackgroundColor are the PWA colors, default is black
+	ThemeColor      string `json:"themeColor,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	// Icons are the PWA icons in dist, default icons are generated from
+	// icon.png of the browser folder
+	Icons []PWAIcon `json:"icons,omitempty"`
+}
+
+// browserPageVars are the variables available in index.html templates
+type browserPageVars struct {
+	templateVars
+	Title        string
+	Width        int
+	Height       int
+	Loading      bool
+	WasmFile     string
+	WasmExecFile string
+}
+
+func (builder *Builder) browserPageVars() browserPageVars {
+	vars := browserPageVars{
+		templateVars: builder.templateVars(),
+		Loading:      true,
+		WasmFile:     wasmFile,
+		WasmExecFile: wasmExecFile,
+	}
+	if builder.manifest != nil {
+		settings := builder.manifest.Browser
+		vars.Title = settings.Title
+		vars.Width = settings.Width
+		vars.Height = settings.Height
+		if settings.Loading != nil {
+			vars.Loading = *settings.Loading
+		}
+	}
+	if vars.Title == "" {
+		vars.Title = vars.AppName
+	}
+	return vars
+}
+
+// writeBrowserPage writes index.html in dist, it is rendered from index.html.tmpl
+// of the browser folder if any, a static index.html is kept as is (browser
+// settings of the manifest are ignored) and the default page is used otherwise.
+func (builder *Builder) writeBrowserPage() error {
+	resourcesPath := filepath.Join(builder.packagePath, builder.target)
+	pagePath := filepath.Join(builder.distPath, browserPageFile)
+
+	source := defaultBrowserPage
+	if content, found := readOptionalFile(filepath.Join(resourcesPath, browserPageFile+templateSuffix)); found {
+		source = string(content)
+		if err := os.Remove(filepath.Join(builder.distPath, browserPageFile+templateSuffix)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if _, err := os.Stat(filepath.Join(resourcesPath, browserPageFile)); err == nil {
+		if manifest := builder.manifest; manifest != nil && (manifest.Browser.Title != "" || manifest.Browser.Width != 0 || manifest.Browser.Height != 0 || manifest.Browser.Loading != nil) {
+			log("WARNING", fmt.Sprintf("browser settings of %s are ignored by the static %s of '%s' folder, rename it to %s to use them", manifestFile, browserPageFile, builder.target, browserPageFile+templateSuffix))
+		}
+		return nil
+	}
+
+	tpl, err := template.New(browserPageFile).Parse(source)
+	if err != nil {
+		return fmt.Errorf("invalid %s template: %s", browserPageFile, err)
+	}
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, builder.browserPageVars()); err != nil {
+		return fmt.Errorf("failed to render %s: %s", browserPageFile, err)
+	}
+	return ioutil.WriteFile(pagePath, buf.Bytes(), 0644)
+}
+
+// copyWasmExec copies wasm_exec.js of the toolchain installed in root to dist,
+// it must match the compiler version used to build main.wasm. A copy in the
+// browser folder is replaced.
+func (builder *Builder) copyWasmExec(root string, paths []string) error {
+	var content []byte
+	found := false
+	for _, dir := range paths {
+		if content, found = readOptionalFile(filepath.Join(root, dir, wasmExecFile)); found {
+			break
+		}
+	}
+	if !found {
+		return newError(errToolchain, nil, "%s not found in toolchain %s", wasmExecFile, root)
+	}
+
+	if custom, found := readOptionalFile(filepath.Join(builder.packagePath, builder.target, wasmExecFile)); found && !bytes.Equal(custom, content) {
+		log("WARNING", fmt.Sprintf("'%s' folder contains a %s not matching the toolchain, it's replaced by %s (remove it from the folder)", builder.target, wasmExecFile, root))
+	}
+	if err := ioutil.WriteFile(filepath.Join(builder.distPath, wasmExecFile), content, 0644); err != nil {
+		return newError(errPackaging, err, "failed to copy %s to dist", wasmExecFile)
+	}
+	return nil
+}
+
+const defaultBrowserPage = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1, user-scalable=no">
+    <title>{{.Title}}</title>
+    <style>
+        html, body { margin: 0; height: 100%; overflow: hidden; background: #000; }
+        {{- if and .Width .Height}}
+        body { display: flex; align-items: center; justify-content: center; }
+        #canvas { width: {{.Width}}px; height: {{.Height}}px; }
+        {{- else}}
+        #canvas { display: block; width: 100%; height: 100%; }
+        {{- end}}
+        #loading { position: fixed; top: 0; left: 0; width: 100%; height: 100%; display: flex;
+            align-items: center; justify-content: center; color: #fff; font: 16px sans-serif; }
+    </style>
+</head>
+<body>
+    {{- if .Loading}}
+    <div id="loading">Loading {{.Title}}...</div>
+    {{- end}}
+    <canvas id="canvas"{{if and .Width .Height}} width="{{.Width}}" height="{{.Height}}"{{end}}></canvas>
+    <script src="{{.WasmExecFile}}"></script>
+    <script>
+        const go = new Go();
+        fetch("{{.WasmFile}}")
+            .then(response => response.arrayBuffer())
+            .then(bytes => WebAssembly.instantiate(bytes, go.importObject))
+            .then(result => {
+                const loading = document.getElementById("loading");
+                if (loading) {
+                    loading.remove();
+                }
+                go.run(result.instance);
+            })
+            .catch(err => console.error(err));
+    </script>
+</body>
+</html>
+`
@@ -6,37 +6,20 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
+	"strings"
 
 	decentcopy "github.com/hugocarreira/go-decent-copy"
 	"github.com/otiai10/copy"
 )
 
 func (builder *Builder) initBuilder(packagePath string) error {
-	if !filepath.IsAbs(packagePath) {
-		builder.packagePath = filepath.Join(builder.cwd, packagePath)
-	} else {
-		builder.packagePath = packagePath
-	}
-
-	if _, err := os.Stat(builder.packagePath); os.IsNotExist(err) {
-		return fmt.Errorf("package path '%s' not found", builder.packagePath)
-	}
-
-	builder.programName = filepath.Base(builder.packagePath)
-	builder.packageName = builder.programName
-
-	if err := os.Chdir(builder.packagePath); err != nil {
-		return err
-	}
-
-	if err := builder.installTGE(); err != nil {
+	if err := builder.openWorkspace(packagePath); err != nil {
 		return err
 	}
 
-	builder.distPath = filepath.Join(builder.packagePath, distPath, builder.target)
+	builder.distPath = filepath.Join(resolvePath(builder.packagePath, builder.config.get("dist")), builder.target)
 
-	if !builder.devMode {
+	if builder.profile.clean {
 		if err := builder.cleanBuilBuilder(); err != nil {
 			log("WARNING", fmt.Sprintf("failed to clean build: %s", err))
 		}
@@ -58,7 +41,7 @@ func (builder *Builder) initBuilder(packagePath string) error {
 		}
 	}
 
-	return nil
+	return builder.runHooks(preBuildHook)
 }
 
 func (builder *Builder) checkCopyResources() error {
@@ -68,43 +51,45 @@ func (builder *Builder) checkCopyResources() error {
 		if err = os.MkdirAll(resourcesInPath, os.ModeDir|0755); err != nil {
 			return err
 		}
-		if err = copy.Copy(filepath.Join(builder.tgeRootPath, tgeTemplatePath, builder.target), resourcesInPath); err != nil {
+		if _, err = renderTemplateDir(filepath.Join(builder.tgeRootPath, tgeTemplatePath, builder.target), resourcesInPath, builder.templateVars(), false); err != nil {
 			return err
 		}
+		if err = builder.saveResourcesBase(builder.target); err != nil {
+			log("WARNING", fmt.Sprintf("failed to save '%s' template for upgrades: %s", builder.target, err))
+		}
 		log("NOTICE", fmt.Sprintf("folder '%s' has been added to your project for customization (see README.md inside)", builder.target))
 	}
 	return nil
 }
 
 func (builder *Builder) installGoMobile() (string, error) {
-	gomobilebin, err := exec.LookPath("gomobile")
+	gomobilebin := builder.config.get("tools.gomobile")
+	var err error
+	if gomobilebin == "" {
+		gomobilebin, err = exec.LookPath("gomobile")
+	}
 	if err != nil {
-		gomobilebin = filepath.Join(builder.goPath, "bin", "gomobile")
+		gomobilebin = filepath.Join(builder.goBin, "gomobile")
 		if _, err = os.Stat(gomobilebin); os.IsNotExist(err) {
+			if builder.offline {
+				return "", newError(errToolchain, nil, "offline mode: gomobile not found in PATH or %s, install it while online", gomobilebin)
+			}
 			log("NOTICE", "installing gomobile in your workspace")
-			cmd := exec.Command("go", "get", "github.com/thommil/tge-mobile/cmd/gomobile")
-			cmd.Env = append(os.Environ(),
-				fmt.Sprintf("GOPATH=%s", builder.goPath),
-			)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				return "", fmt.Errorf("failed to install gomobile")
+			cmd := exec.Command("go", "install", "github.com/thommil/tge-mobile/cmd/gomobile@latest")
+			cmd.Env = builder.environ()
+			if err := runCommand(cmd); err != nil {
+				return "", newError(errToolInstall, err, "failed to install gomobile")
 			}
 		}
 	}
 
 	if builder.target == "android" {
-		if _, err = os.Stat(filepath.Join(builder.goPath, "pkg", "gomobile")); os.IsNotExist(err) {
+		if _, err = os.Stat(filepath.Join(filepath.SplitList(builder.goPath)[0], "pkg", "gomobile")); os.IsNotExist(err) {
 			log("NOTICE", "initializing gomobile")
 			cmd := exec.Command(gomobilebin, "init")
-			cmd.Env = append(os.Environ(),
-				fmt.Sprintf("GOPATH=%s", builder.goPath),
-			)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				return "", fmt.Errorf("failed to initialize gomobile")
+			cmd.Env = builder.environ()
+			if err := runCommand(cmd); err != nil {
+				return "", newError(errToolInstall, err, "failed to initialize gomobile")
 			}
 		}
 	}
@@ -127,69 +112,55 @@ func (builder *Builder) buildAndroid(packagePath string) error {
 
 	// Resources
 	if err := builder.checkCopyResources(); err != nil {
-		return fmt.Errorf("failed to copy resources files: %s", err)
+		return newError(errProject, err, "failed to copy resources files")
 	}
 
 	if _, err := os.Stat(filepath.Join(builder.packagePath, "android", "AndroidManifest.xml")); os.IsNotExist(err) {
 		if err = decentcopy.Copy(filepath.Join(builder.tgeRootPath, tgeTemplatePath, "android", "AndroidManifest.xml"), filepath.Join(builder.packagePath, "AndroidManifest.xml")); err != nil {
-			return fmt.Errorf("WARNING", "failed to copy AndroidManifest.xml from TGE, using default gombile one: %s", err)
+			return newError(errProject, err, "failed to copy AndroidManifest.xml from TGE")
 		}
 	} else {
 		if err = decentcopy.Copy(filepath.Join(builder.packagePath, builder.target, "AndroidManifest.xml"), filepath.Join(builder.packagePath, "AndroidManifest.xml")); err != nil {
-			return fmt.Errorf("WARNING", "failed to copy AndroidManifest.xml, using default gombile one: %s", err)
+			return newError(errProject, err, "failed to copy AndroidManifest.xml")
 		}
 	}
 	defer os.Remove(filepath.Join(builder.packagePath, "AndroidManifest.xml"))
 
 	if err = decentcopy.Copy(filepath.Join(builder.packagePath, builder.target, "icon.png"), filepath.Join(builder.packagePath, "assets", "icon.png")); err != nil {
-		return fmt.Errorf("WARNING", "failed to copy icon.png, not found")
+		return newError(errProject, err, "failed to copy icon.png")
 	}
 	defer os.Remove(filepath.Join(builder.packagePath, "assets", "icon.png"))
 
-	if builder.devMode {
+	injected := builder.profileBuildFlags()
+
+	if builder.profile.universalAPK {
 		var cmd *exec.Cmd
-		cmdParams := []string{"build", "-target=android"}
-		if builder.verbose {
-			cmdParams = append(cmdParams, "-v")
-		}
-		if builder.devMode {
-			cmdParams = append(cmdParams, "-tags=debug")
-		}
+		cmdParams := append([]string{"build", "-target=android"}, builder.goBuildParams(injected)...)
 		cmdParams = append(cmdParams, "-o", filepath.Join(builder.distPath, fmt.Sprintf("%s.apk", builder.programName)))
 		cmd = exec.Command(gomobilebin, cmdParams...)
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("GOPATH=%s", builder.goPath),
-		)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to build android application")
+		cmd.Env = builder.environ()
+		if err := runCommand(cmd); err != nil {
+			return newError(errCompile, err, "failed to build android application")
 		}
 	} else {
 		for _, t := range []string{"arm", "386", "amd64", "arm64"} {
 			var cmd *exec.Cmd
-			cmdParams := []string{"build", fmt.Sprintf("-target=android/%s", t)}
-			if builder.verbose {
-				cmdParams = append(cmdParams, "-v")
-			}
-			if builder.devMode {
-				cmdParams = append(cmdParams, "-tags=debug")
-			}
+			cmdParams := append([]string{"build", fmt.Sprintf("-target=android/%s", t)}, builder.goBuildParams(injected)...)
 			cmdParams = append(cmdParams, "-o", filepath.Join(builder.distPath, fmt.Sprintf("%s-%s.apk", builder.programName, t)))
 			cmd = exec.Command(gomobilebin, cmdParams...)
-			cmd.Env = append(os.Environ(),
-				fmt.Sprintf("GOPATH=%s", builder.goPath),
-			)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("failed to build android application (arch %s)", t)
+			cmd.Env = builder.environ()
+			if err := runCommand(cmd); err != nil {
+				return newError(errCompile, err, "failed to build android application (arch %s)", t)
 			}
 		}
 
 	}
 
-	return nil
+	if err := builder.runHooks(postCompileHook); err != nil {
+		return err
+	}
+
+	return builder.runHooks(postPackageHook)
 }
 
 func (builder *Builder) buildIOS(packagePath string, bundleID string) error {
@@ -208,35 +179,30 @@ func (builder *Builder) buildIOS(packagePath string, bundleID string) error {
 
 	// Resources
 	if err := builder.checkCopyResources(); err != nil {
-		return fmt.Errorf("failed to copy resources files: %s", err)
+		return newError(errProject, err, "failed to copy resources files")
 	}
 
 	if err = decentcopy.Copy(filepath.Join(builder.packagePath, builder.target, "icon.png"), filepath.Join(builder.packagePath, "assets", "icon.png")); err != nil {
-		return fmt.Errorf("WARNING", "failed to copy icon.png, not found")
+		return newError(errProject, err, "failed to copy icon.png")
 	}
 	defer os.Remove(filepath.Join(builder.packagePath, "assets", "icon.png"))
 
 	// Build
 	var cmd *exec.Cmd
-	cmdParams := []string{"build", "-target=ios", fmt.Sprintf("-bundleid=%s", bundleID)}
-	if builder.verbose {
-		cmdParams = append(cmdParams, "-v")
-	}
-	if builder.devMode {
-		cmdParams = append(cmdParams, "-tags=debug")
-	}
+	injected := builder.profileBuildFlags()
+	cmdParams := append([]string{"build", "-target=ios", fmt.Sprintf("-bundleid=%s", bundleID)}, builder.goBuildParams(injected)...)
 	cmdParams = append(cmdParams, "-o", filepath.Join(builder.distPath, fmt.Sprintf("%s.app", builder.programName)))
 	cmd = exec.Command(gomobilebin, cmdParams...)
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("GOPATH=%s", builder.goPath),
-	)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to build IOS application")
+	cmd.Env = builder.environ()
+	if err := runCommand(cmd); err != nil {
+		return newError(errCompile, err, "failed to build IOS application")
 	}
 
-	return nil
+	if err := builder.runHooks(postCompileHook); err != nil {
+		return err
+	}
+
+	return builder.runHooks(postPackageHook)
 }
 
 func (builder *Builder) buildBrowser(packagePath string) error {
@@ -249,60 +215,92 @@ func (builder *Builder) buildBrowser(packagePath string) error {
 
 	// Build
 	var cmd *exec.Cmd
-	cmdParams := []string{"build"}
-	if builder.verbose {
-		cmdParams = append(cmdParams, "-v")
-	}
-	if builder.devMode {
-		cmdParams = append(cmdParams, "-tags=debug")
-	}
-	cmdParams = append(cmdParams, "-o", filepath.Join(builder.distPath, "main.wasm"))
-	cmd = exec.Command("go", cmdParams...)
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("GOPATH=%s", builder.goPath),
-		"GOOS=js",
-		"GOARCH=wasm",
-	)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log("ERROR", "failed to build browser application")
-		return fmt.Errorf("failed to build application")
+	compiler := builder.config.get("compiler")
+	wasmExecRoot, wasmExecDirs := builder.goRoot, wasmExecPaths
+	if compiler == tinygoCompiler {
+		tinygobin, err := builder.lookupTinyGo()
+		if err != nil {
+			return err
+		}
+		if wasmExecRoot, err = builder.tinygoRoot(tinygobin); err != nil {
+			return err
+		}
+		wasmExecDirs = tinygoWasmExecPaths
+		cmdParams := append(builder.tinygoBuildParams(), "-o", filepath.Join(builder.distPath, wasmFile), ".")
+		cmd = exec.Command(tinygobin, cmdParams...)
+		cmd.Env = builder.environ()
+	} else {
+		injected := builder.profileBuildFlags()
+		cmdParams := append([]string{"build"}, builder.goBuildParams(injected)...)
+		cmdParams = append(cmdParams, "-o", filepath.Join(builder.distPath, wasmFile))
+		cmd = exec.Command("go", cmdParams...)
+		cmd.Env = builder.environ(
+			"GOOS=js",
+			"GOARCH=wasm",
+		)
+	}
+	if err := runCommand(cmd); err != nil {
+		return newError(errCompile, err, "failed to build browser application with %s", compiler)
 	}
+	builder.reportWasmSize(compiler)
+	if err := builder.runHooks(postCompileHook); err != nil {
+		return err
+	}
+
 	// Resources
 	if err := builder.checkCopyResources(); err != nil {
-		return fmt.Errorf("failed to retrieve resources files from TGE: %s", err)
+		return newError(errProject, err, "failed to retrieve resources files from TGE")
 	}
 
 	if err := copy.Copy(filepath.Join(builder.packagePath, builder.target), builder.distPath); err != nil {
-		return fmt.Errorf("failed to copy resources files to dist: %s", err)
+		return newError(errPackaging, err, "failed to copy resources files to dist")
+	}
+	if err := builder.writeBrowserPage(); err != nil {
+		return newError(errPackaging, err, "failed to write %s to dist", browserPageFile)
+	}
+	if err := builder.copyWasmExec(wasmExecRoot, wasmExecDirs); err != nil {
+		return err
 	}
 
 	// Assets
 	assetsOutPath := filepath.Join(builder.distPath, assetsPath)
 	if _, err := os.Stat(assetsOutPath); os.IsNotExist(err) {
 		if err := os.MkdirAll(assetsOutPath, os.ModeDir|0755); err != nil {
-			return err
+			return newError(errPackaging, err, "failed to create assets folder in dist")
 		}
 		if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
-			return err
+			return newError(errPackaging, err, "failed to copy assets to dist")
 		}
 		log("NOTICE", fmt.Sprintf("Copying assets to dist: %s", assetsOutPath))
-	} else if !builder.devMode {
+	} else if builder.profile.assets {
 		log("NOTICE", fmt.Sprintf("Copying assets to dist: %s", assetsOutPath))
 		if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
-			return err
+			return newError(errPackaging, err, "failed to copy assets to dist")
 		}
 	} else {
-		log("NOTICE", fmt.Sprintf("Skipping assets (DEV mode), found in dist: %s", assetsOutPath))
+		log("NOTICE", fmt.Sprintf("Skipping assets (%s profile), found in dist: %s", builder.profile.name, assetsOutPath))
 	}
 
-	return nil
+	// Single file
+	if builder.singleFile || (builder.manifest != nil && builder.manifest.Browser.SingleFile) {
+		if err := builder.packageSingleFile(); err != nil {
+			return newError(errPackaging, err, "failed to generate single HTML file")
+		}
+	}
+
+	// PWA
+	if builder.pwa || (builder.manifest != nil && builder.manifest.Browser.PWA) {
+		if err := builder.packagePWA(); err != nil {
+			return newError(errPackaging, err, "failed to generate PWA")
+		}
+	}
+
+	return builder.runHooks(postPackageHook)
 }
 
 func (builder *Builder) buildDesktop(packagePath string) error {
 	// Init
-	switch runtime.GOOS {
+	switch goOS() {
 	case "darwin":
 		builder.target = "darwin"
 	case "windows":
@@ -310,7 +308,7 @@ func (builder *Builder) buildDesktop(packagePath string) error {
 	case "linux":
 		builder.target = "linux"
 	default:
-		return fmt.Errorf("unsupported desktop target: '%s'", runtime.GOOS)
+		return newError(errUsage, nil, "unsupported desktop target: '%s'", goOS())
 	}
 
 	if err := builder.initBuilder(packagePath); err != nil {
@@ -324,7 +322,7 @@ func (builder *Builder) buildDesktop(packagePath string) error {
 
 	// Resources
 	if err := builder.checkCopyResources(); err != nil {
-		return fmt.Errorf("failed to retrieve resources files from TGE : %s", err)
+		return newError(errProject, err, "failed to retrieve resources files from TGE")
 	}
 
 	// Build & packaging
@@ -333,155 +331,112 @@ func (builder *Builder) buildDesktop(packagePath string) error {
 	switch builder.target {
 	case "darwin":
 		// Build
-		cmdParams := []string{"build"}
-		if builder.verbose {
-			cmdParams = append(cmdParams, "-v")
-		}
-		if builder.devMode {
-			cmdParams = append(cmdParams, "-tags=debug")
-		}
+		injected := builder.profileBuildFlags()
+		cmdParams := append([]string{"build"}, builder.goBuildParams(injected)...)
 		cmdParams = append(cmdParams, "-o", filepath.Join(builder.distPath, binaryFile))
 		cmd = exec.Command("go", cmdParams...)
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("GOPATH=%s", builder.goPath),
-		)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to build application")
+		cmd.Env = builder.environ()
+		if err := runCommand(cmd); err != nil {
+			return newError(errCompile, err, "failed to build desktop application")
+		}
+		if err := builder.runHooks(postCompileHook); err != nil {
+			return err
 		}
 
 		// Packaging
-		if !builder.devMode {
-			appifybin, err := exec.LookPath("appify")
-			if err != nil {
-				appifybin = filepath.Join(builder.goPath, "bin", "appify")
-				if _, err = os.Stat(appifybin); os.IsNotExist(err) {
-					log("NOTICE", "installing appify in your workspace")
-					cmd = exec.Command("go", "get", "github.com/machinebox/appify")
-					cmd.Env = append(os.Environ(),
-						fmt.Sprintf("GOPATH=%s", builder.goPath),
-					)
-					cmd.Stdout = os.Stdout
-					cmd.Stderr = os.Stderr
-					if err := cmd.Run(); err != nil {
-						appifybin = ""
-						log("WARNING", "failed to install appify, unable to package MacOS application")
-					}
-				}
-			}
-
-			if appifybin != "" {
-				os.Chdir(builder.distPath)
-				cmd := exec.Command(appifybin, "-name", builder.programName, "-icon",
-					filepath.Join(builder.packagePath, builder.target, "icon.icns"), filepath.Join(builder.distPath, builder.programName))
-				cmd.Env = append(os.Environ(),
-					fmt.Sprintf("GOPATH=%s", builder.goPath),
-				)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				if err := cmd.Run(); err != nil {
-					log("WARNING", "failed to package MacOS application")
-				}
+		if !builder.profile.console {
+			var err error
+			if assetsOutPath, err = builder.packageMacOS(binaryFile); err != nil {
+				return newError(errPackaging, err, "failed to package MacOS application")
 			}
 
-			os.RemoveAll(filepath.Join(builder.distPath, binaryFile))
-
 			// Assets
-			assetsOutPath = filepath.Join(builder.distPath, fmt.Sprintf("%s.app", builder.programName), "Contents", "Resources")
-			log("NOTICE", fmt.Sprintf("Copying assets in dist: %s", assetsOutPath))
-			if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
-				return err
+			if builder.profile.assets {
+				log("NOTICE", fmt.Sprintf("Copying assets in dist: %s", assetsOutPath))
+				if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
+					return newError(errPackaging, err, "failed to copy assets to dist")
+				}
+			} else {
+				log("NOTICE", fmt.Sprintf("Skipping assets (%s profile)", builder.profile.name))
 			}
 		}
 
 	case "windows":
-		// Packaging
-		if !builder.devMode {
-			goversioninfobin, err := exec.LookPath("goversioninfo.exe")
+		// Build
+		injected := builder.profileBuildFlags()
+		if !builder.profile.console {
+			resourcesParams, cleanup, err := builder.writeWindowsResources(binaryFile)
 			if err != nil {
-				goversioninfobin = filepath.Join(builder.goPath, "bin", "goversioninfo.exe")
-				if _, err = os.Stat(goversioninfobin); os.IsNotExist(err) {
-					log("NOTICE", "installing goversioninfo in your workspace")
-					cmd = exec.Command("go", "get", "github.com/josephspurrier/goversioninfo/cmd/goversioninfo")
-					cmd.Env = append(os.Environ(),
-						fmt.Sprintf("GOPATH=%s", builder.goPath),
-					)
-					cmd.Stdout = os.Stdout
-					cmd.Stderr = os.Stderr
-					if err := cmd.Run(); err != nil {
-						goversioninfobin = ""
-						log("WARNING", "failed to install goversioninfo, unable to package Windows application")
-					}
-				}
+				return newError(errPackaging, err, "failed to prepare package for Windows application")
 			}
+			defer cleanup()
+			injected.args = append(injected.args, resourcesParams...)
+			injected.ldflags = append(injected.ldflags, "-H=windowsgui")
+		}
+		cmdParams := append([]string{"build"}, builder.goBuildParams(injected)...)
+		cmdParams = append(cmdParams, "-o", filepath.Join(builder.distPath, binaryFile))
+		cmd = exec.Command("go", cmdParams...)
+		cmd.Env = builder.environ()
+		if err := runCommand(cmd); err != nil {
+			return newError(errCompile, err, "failed to build desktop application")
+		}
+		if err := builder.runHooks(postCompileHook); err != nil {
+			return err
+		}
 
-			if goversioninfobin != "" {
-				if err := decentcopy.Copy(filepath.Join(builder.packagePath, builder.target, "versioninfo.json"), filepath.Join(builder.packagePath, "versioninfo.json")); err != nil {
-					log("WARNING", "failed to prepare package for Windows application")
-				} else {
-					defer os.Remove(filepath.Join(builder.packagePath, "resource_windows_386.syso"))
-					defer os.Remove(filepath.Join(builder.packagePath, "resource_windows_amd64.syso"))
-				}
-				defer os.Remove(filepath.Join(builder.packagePath, "versioninfo.json"))
-
-				cmd := exec.Command(goversioninfobin, "-platform-specific=true", "-manifest", filepath.Join(builder.packagePath, builder.target, "main.exe.manifest"), "-icon",
-					filepath.Join(builder.packagePath, builder.target, "icon.ico"))
-				cmd.Env = append(os.Environ(),
-					fmt.Sprintf("GOPATH=%s", builder.goPath),
-				)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				if err := cmd.Run(); err != nil {
-					log("WARNING", "failed to prepare package for Windows application")
-				}
+		// Assets
+		assetsOutPath = filepath.Join(builder.distPath, assetsPath)
+		if _, err := os.Stat(assetsOutPath); os.IsNotExist(err) {
+			if err := os.MkdirAll(assetsOutPath, os.ModeDir|0755); err != nil {
+				return newError(errPackaging, err, "failed to create assets folder in dist")
 			}
+			if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
+				return newError(errPackaging, err, "failed to copy assets to dist")
+			}
+			log("NOTICE", fmt.Sprintf("Copying assets in dist: %s", assetsOutPath))
+		} else if builder.profile.assets {
+			log("NOTICE", fmt.Sprintf("Copying assets in dist: %s", assetsOutPath))
+			if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
+				return newError(errPackaging, err, "failed to copy assets to dist")
+			}
+		} else {
+			log("NOTICE", fmt.Sprintf("Skipping assets (%s profile), found in dist: %s", builder.profile.name, assetsOutPath))
 		}
 
+	case "linux":
 		// Build
-		cmdParams := []string{"build"}
-		if builder.verbose {
-			cmdParams = append(cmdParams, "-v")
-		}
-		if builder.devMode {
-			cmdParams = append(cmdParams, "-tags=debug")
-		} else {
-			cmdParams = append(cmdParams, "-ldflags", "-H=windowsgui")
-		}
+		injected := builder.profileBuildFlags()
+		cmdParams := append([]string{"build"}, builder.goBuildParams(injected)...)
 		cmdParams = append(cmdParams, "-o", filepath.Join(builder.distPath, binaryFile))
 		cmd = exec.Command("go", cmdParams...)
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("GOPATH=%s", builder.goPath),
-		)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to build application")
+		cmd.Env = builder.environ()
+		if err := runCommand(cmd); err != nil {
+			return newError(errCompile, err, "failed to build desktop application")
+		}
+		if err := builder.runHooks(postCompileHook); err != nil {
+			return err
 		}
 
 		// Assets
-		if !builder.devMode {
-			assetsOutPath = filepath.Join(builder.distPath, assetsPath)
-			if _, err := os.Stat(assetsOutPath); os.IsNotExist(err) {
-				if err := os.MkdirAll(assetsOutPath, os.ModeDir|0755); err != nil {
-					return err
-				}
-				if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
-					return err
-				}
-				log("NOTICE", fmt.Sprintf("Copying assets in dist: %s", assetsOutPath))
-			} else if !builder.devMode {
-				log("NOTICE", fmt.Sprintf("Copying assets in dist: %s", assetsOutPath))
-				if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
-					return err
-				}
-			} else {
-				log("NOTICE", fmt.Sprintf("Skipping assets (DEV mode), found in dist: %s", assetsOutPath))
+		assetsOutPath = filepath.Join(builder.distPath, assetsPath)
+		if builder.profile.assets {
+			log("NOTICE", fmt.Sprintf("Copying assets in dist: %s", assetsOutPath))
+			if err := copy.Copy(builder.assetsPath, assetsOutPath); err != nil {
+				return newError(errPackaging, err, "failed to copy assets to dist")
+			}
+		} else {
+			log("NOTICE", fmt.Sprintf("Skipping assets (%s profile)", builder.profile.name))
+		}
+
+		// Packaging
+		if builder.packageFormat != "" {
+			if err := builder.packageLinux(builder.packageFormat, binaryFile); err != nil {
+				return newError(errPackaging, err, "failed to package Linux application")
 			}
 		}
 	}
 
-	return nil
+	return builder.runHooks(postPackageHook)
 }
 
 func (builder *Builder) cleanBuilBuilder() error {
@@ -491,86 +446,154 @@ func (builder *Builder) cleanBuilBuilder() error {
 	return nil
 }
 
-func doBuild(builder Builder) {
-	targetFlag := flag.String("target", "desktop", "build target : desktop, android, ios, browser")
-	verboseFlag := flag.Bool("v", false, "verbose ouput for debugging")
-	devModeFlag := flag.Bool("dev", false, "Dev mode, skip clean, assets copy & arch split (faster)")
-	bundleIDFlag := flag.String("bundleid", "", "IOS only, bundleId to use for app")
-	os.Args = os.Args[1:]
-	flag.Usage = func() { fmt.Println(buildUsage) }
-	flag.Parse()
-
-	if len(flag.Args()) == 0 {
-		fmt.Println(buildUsage)
+var buildCommand = &command{
+	name:        "build",
+	summary:     "Build & package TGE applications",
+	synopsis:    []string{"[flags] packagePath [-- go build args...]"},
+	passthrough: true,
+	help: `The package path must point to a valid TGE application, the generated
+application will be stored in the dist/$TARGET folder.
+
+Arguments following '--' are passed as is to go build (or gomobile build), ex:
+    tge-cli build -tags prod -ldflags "-X main.version=1.0" . -- -race -trimpath`,
+	notes: `Profiles, build flags, hooks and size budgets are set in the project manifest
+(see 'tge-cli help manifest'), browser pages and desktop packages are detailed
+in 'tge-cli help browser' and 'tge-cli help desktop'.
+
+Default values of -target, -profile, -compiler, -v, -offline, -tge-path and
+-tge-version can be set in user configuration, project manifest (tge.json) or TGE_* environment
+variables, see 'tge-cli help config'.`,
+	completions: map[string][]string{
+		"target":   {"desktop", "browser", "android", "ios"},
+		"profile":  {debugProfile, releaseProfile},
+		"compiler": browserCompilers,
+		"package":  linuxPackageFormats,
+		"tge-path": dirsCompletion,
+	},
+	flags: func(fs *flag.FlagSet) {
+		fs.String("target", "", "defines the application `target`:\n"+
+			"    desktop (default)\n"+
+			"    browser\n"+
+			"    android\n"+
+			"    ios\n"+
+			"\n"+
+			"For desktop target, the generated application depends on current OS:\n"+
+			"    MacOS   -> darwin\n"+
+			"    Windows -> windows\n"+
+			"    Linux   -> linux\n"+
+			"\n"+
+			"For each target, the corresponding folder in your workspace will contain\n"+
+			"additional ressources for more customization (see README.md files)")
+		fs.String("profile", "", "build `profile`, built-in profiles are:\n"+
+			"    release (default)   clean build, assets copy, one APK per\n"+
+			"                        architecture on Android, packed desktop\n"+
+			"                        applications without console\n"+
+			"    debug               no clean and assets only copied if missing\n"+
+			"                        (faster), universal APK on Android,\n"+
+			"                        unpacked desktop applications with console,\n"+
+			"                        debug build tag")
+		fs.Bool("dev", false, "alias for -profile debug")
+		fs.Bool("pwa", false, "generate a Progressive Web App for browser target (web app manifest\nand service worker precaching the application)")
+		fs.Bool("single-file", false, "also package browser target as a self-contained HTML file in\ndist/$PROGRAM.html, with main.wasm and assets inlined")
+		fs.String("package", "", "also package Linux desktop application in the given `format`:\n    deb        Debian package\n    appimage   AppDir, and AppImage if appimagetool is found")
+		fs.String("compiler", "", "`compiler` of browser target, go (default) or tinygo for smaller\nbinaries (TinyGo must be installed, see https://tinygo.org)")
+		verboseFlag(fs)
+		fs.String("bundleid", "", "bundle `id`, mandatory for IOS build and can be obtained from Apple\nDeveloper")
+		fs.Bool("offline", false, "never access network, TGE is resolved from -tge-path, the vendor\ndirectory or the modules cache and tools must be already installed")
+		tgePathFlag(fs)
+		fs.String("tge-version", "", "TGE `version` to use (tag, commit or pseudo-version), recorded in the\ngo.mod requirement of the project. Default is the version already\nrequired by the project.")
+		fs.String("tags", "", "comma separated build `tags`, added to the ones set by tge-cli (debug\ntag and tags of the profile)")
+		fs.String("ldflags", "", "`flags` to pass on each go tool link invocation, appended to the\nones set by tge-cli (-s -w when the profile strips binaries,\n-H=windowsgui for Windows without console)")
+		fs.String("gcflags", "", "`flags` to pass on each go tool compile invocation")
+		fs.Bool("size-report", false, "print the raw and gzipped sizes of the generated files along with the\nsize of each Go package in binaries, the report is saved in\ndist/$TARGET-size.json")
+		fs.String("size-diff", "", "compare the size report with a previous report `file` (implies\n-size-report)")
+	},
+	run: doBuild,
+}
+
+func doBuild(builder Builder, fs *flag.FlagSet, args []string) {
+	if len(args) == 0 || args[0] == "--" {
+		printCommandHelp("build")
 		return
 	}
 
-	builder.devMode = *devModeFlag
-	builder.verbose = *verboseFlag
-	switch *targetFlag {
-	case "desktop":
-		if err := builder.buildDesktop(flag.Args()[0]); err != nil {
-			log("ERROR", err.Error())
-			builder.cleanBuilBuilder()
-			os.Exit(1)
+	packagePath := args[0]
+	builder.buildFlags = BuildFlags{Ldflags: flagString(fs, "ldflags"), Gcflags: flagString(fs, "gcflags")}
+	if tags := flagString(fs, "tags"); tags != "" {
+		builder.buildFlags.Tags = []string{tags}
+	}
+	if len(args) > 1 {
+		if args[1] != "--" {
+			fail(newError(errUsage, nil, "unexpected argument '%s', go build arguments must follow '--'", args[1]))
 		}
-	case "browser":
-		if err := builder.buildBrowser(flag.Args()[0]); err != nil {
-			log("ERROR", err.Error())
-			builder.cleanBuilBuilder()
-			os.Exit(1)
+		builder.buildFlags.Args = args[2:]
+	}
+	builder.flags = flagSettings(fs, "target", "v", "profile", "compiler", "offline", "tge-path", "tge-version")
+	if flagBool(fs, "dev") {
+		if profile := flagString(fs, "profile"); profile != "" && profile != debugProfile {
+			fail(newError(errUsage, nil, "-dev is an alias for -profile %s, it can't be used with -profile %s", debugProfile, profile))
 		}
-	case "android":
-		if err := builder.buildAndroid(flag.Args()[0]); err != nil {
-			log("ERROR", err.Error())
-			builder.cleanBuilBuilder()
-			os.Exit(1)
+		builder.flags["profile"] = debugProfile
+	}
+	if err := builder.loadConfig(resolvePath(builder.cwd, packagePath)); err != nil {
+		fail(err)
+	}
+	profile, err := resolveProfile(builder.config.get("profile"), builder.manifest)
+	if err != nil {
+		fail(err)
+	}
+	builder.profile = profile
+	log("NOTICE", fmt.Sprintf("using %s profile", profile.name))
+	target := builder.config.get("target")
+	if compiler := builder.config.get("compiler"); indexOf(browserCompilers, compiler) < 0 {
+		fail(newError(errUsage, nil, "unsupported compiler '%s' (available: %s)", compiler, strings.Join(browserCompilers, ", ")))
+	} else if compiler != goCompiler && target != "browser" {
+		log("WARNING", fmt.Sprintf("%s compiler is only supported by browser target, ignored", compiler))
+	}
+	builder.pwa = flagBool(fs, "pwa")
+	if builder.pwa && target != "browser" {
+		log("WARNING", "-pwa is only supported by browser target, ignored")
+	}
+	builder.singleFile = flagBool(fs, "single-file")
+	if builder.singleFile && target != "browser" {
+		log("WARNING", "-single-file is only supported by browser target, ignored")
+	}
+	builder.packageFormat = flagString(fs, "package")
+	if builder.packageFormat != "" {
+		if indexOf(linuxPackageFormats, builder.packageFormat) < 0 {
+			fail(newError(errUsage, nil, "unsupported package format '%s' (available: %s)", builder.packageFormat, strings.Join(linuxPackageFormats, ", ")))
+		} else if target != "desktop" || goOS() != "linux" {
+			log("WARNING", "-package is only supported by Linux desktop target, ignored")
+			builder.packageFormat = ""
 		}
+	}
+	switch target {
+	case "desktop":
+		err = builder.buildDesktop(packagePath)
+	case "browser":
+		err = builder.buildBrowser(packagePath)
+	case "android":
+		err = builder.buildAndroid(packagePath)
 	case "ios":
-		if *bundleIDFlag == "" {
-			log("ERROR", "missing bundleId for IOS (set with -bundleid)")
-			os.Exit(1)
-		}
-		if err := builder.buildIOS(flag.Args()[0], *bundleIDFlag); err != nil {
-			log("ERROR", err.Error())
-			builder.cleanBuilBuilder()
-			os.Exit(1)
+		bundleID := flagString(fs, "bundleid")
+		if bundleID == "" {
+			fail(newError(errUsage, nil, "missing bundleId for IOS (set with -bundleid)"))
 		}
+		err = builder.buildIOS(packagePath, bundleID)
 	default:
-		log("ERROR", fmt.Sprintf("unsupported target '%s'", *targetFlag))
-		os.Exit(1)
+		fail(newError(errUsage, nil, "unsupported target '%s'", target))
+	}
+	if err != nil {
+		builder.cleanBuilBuilder()
+		fail(err)
+	}
+	if err := builder.checkSizes(flagBool(fs, "size-report"), flagString(fs, "size-diff")); err != nil {
+		fail(err)
+	}
+
+	if err := builder.runHooks(postBuildHook); err != nil {
+		fail(err)
 	}
 
 	log("SUCCESS", fmt.Sprintf("Application is available in %s", builder.distPath))
 }
-
-var buildUsage = `tge-cli build build and deploys TGE applications.
-	
-Usage:
-    tge-cli build [-target TARGET] [-dev] [-v] [-bundleid ID] packagePath
-
-The package path must point to a valid TGE application, the generated
-application will be stored in the dist/$TARGET folder.
-
--target     defines the application target:
-                desktop (default)
-                browser
-                android
-                ios
-
-            For desktop target, the generated application depends on current OS:
-                MacOS   -> darwin
-                Windows -> windows
-                Linux   -> linux
-
-            For each target, the corresponding folder in your workspace will contain
-            additional ressources for more customization (see README.md files)
-
--dev        dev flag allows to generate application faster by omitting assets copy.
-            Desktop applications are not packed and console remains opened.
-            On Android the resulting APK will support all architectures.
-            Debug mode is also enabled.
-
--v          verbose output for debugging purpose
-
--bundleid  is mandatory for IOS build and can be obtained from Apple Developer.`
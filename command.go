@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// command is a tge-cli subcommand, its help and shell completion are generated
+// from its flag set.
+type command struct {
+	name    string
+	summary string
+	// synopsis lists the usage lines following 'tge-cli name'
+	synopsis []string
+	// help is printed after the usage lines, notes after the flags
+	help  string
+	notes string
+	// subcommands are completed as first argument
+	subcommands []string
+	// completions lists the values completed for flags, dirs is used for
+	// directory flags
+	completions map[string][]string
+	// passthrough keeps arguments following '--' (after a '--' argument)
+	passthrough bool
+	flags       func(fs *flag.FlagSet)
+	run         func(builder Builder, fs *flag.FlagSet, args []string)
+}
+
+// dirsCompletion completes a flag value with directories
+var dirsCompletion = []string{"<dirs>"}
+
+var commands []*command
+
+func init() {
+	commands = []*command{initCommand, buildCommand, packageCommand, upgradeCommand, resourcesCommand, configCommand, versionCommand, helpCommand, completionCommand}
+}
+
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// printCommandHelp prints the help of the command called name
+func printCommandHelp(name string) {
+	lookupCommand(name).printHelp()
+}
+
+// flagSet returns a new flag set defining the command flags
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if c.flags != nil {
+		c.flags(fs)
+	}
+	return fs
+}
+
+// parseArgs parses flags placed anywhere in args and returns the positional
+// arguments. Arguments following '--' are never parsed, with passthrough
+// they are returned after a '--' argument.
+func parseArgs(fs *flag.FlagSet, args []string, passthrough bool) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			if passthrough {
+				positional = append(positional, "--")
+			}
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// execute parses args and runs the command, it exits with errUsage status on
+// invalid flags.
+func (c *command) execute(builder Builder, args []string) {
+	fs := c.flagSet()
+	positional, err := parseArgs(fs, args, c.passthrough)
+	if err == flag.ErrHelp {
+		c.printHelp()
+		return
+	} else if err != nil {
+		fail(newError(errUsage, nil, "%s (see 'tge-cli help %s')", err, c.name))
+	}
+	c.run(builder, fs, positional)
+}
+
+func (c *command) printHelp() {
+	fmt.Printf("%s.\n\nUsage:\n", c.summary)
+	for _, synopsis := range c.synopsis {
+		fmt.Printf("    tge-cli %s %s\n", c.name, synopsis)
+	}
+	if c.help != "" {
+		fmt.Printf("\n%s\n", c.help)
+	}
+
+	fs := c.flagSet()
+	hasFlags := false
+	fs.VisitAll(func(f *flag.Flag) {
+		if !hasFlags {
+			fmt.Println("\nFlags:")
+			hasFlags = true
+		}
+		name, usage := flag.UnquoteUsage(f)
+		header := "-" + f.Name
+		if name != "" {
+			header = fmt.Sprintf("%s %s", header, strings.ToUpper(name))
+		}
+		lines := strings.Split(usage, "\n")
+		if len(header) < 12 {
+			fmt.Printf("%-12s%s\n", header, lines[0])
+		} else {
+			fmt.Printf("%s\n%12s%s\n", header, "", lines[0])
+		}
+		for _, line := range lines[1:] {
+			if line == "" {
+				fmt.Println()
+			} else {
+				fmt.Printf("%12s%s\n", "", line)
+			}
+		}
+		fmt.Println()
+	})
+	if c.notes != "" {
+		if !hasFlags {
+			fmt.Println()
+		}
+		fmt.Printf("%s\n", c.notes)
+	}
+}
+
+// flagString returns the value of a string flag
+func flagString(fs *flag.FlagSet, name string) string {
+	return fs.Lookup(name).Value.String()
+}
+
+// flagBool returns the value of a bool flag
+func flagBool(fs *flag.FlagSet, name string) bool {
+	value, _ := fs.Lookup(name).Value.(flag.Getter).Get().(bool)
+	return value
+}
+
+// Shared flags
+func offlineFlag(fs *flag.FlagSet) {
+	fs.Bool("offline", false, "never access network, TGE is resolved from -tge-path, the vendor\ndirectory or the modules cache")
+}
+
+func tgePathFlag(fs *flag.FlagSet) {
+	fs.String("tge-path", "", "use a local TGE checkout in `dir`, wired to the project with a replace\ndirective in .tge/go.local.mod, go.mod is left untouched")
+}
+
+func verboseFlag(fs *flag.FlagSet) {
+	fs.Bool("v", false, "verbose output for debugging purpose")
+}
+
+var helpCommand = &command{
+	name:     "help",
+	summary:  "Print help of tge-cli, a command, a topic or a plugin",
+	synopsis: []string{"[command|topic]"},
+	run: func(builder Builder, fs *flag.FlagSet, args []string) {
+		if len(args) == 0 {
+			printUsage(builder)
+			return
+		}
+		if c := lookupCommand(args[0]); c != nil {
+			c.printHelp()
+			return
+		}
+		if t := lookupHelpTopic(args[0]); t != nil {
+			t.printHelp()
+			return
+		}
+		if p := lookupPlugin(findProject(builder.cwd), args[0]); p != nil {
+			builder.runPlugin(p, []string{"-h"})
+		}
+		fail(newError(errUsage, nil, "unknown command '%s'", args[0]))
+	},
+}
+
+// printUsage prints the main usage followed by the available plugins
+func printUsage(builder Builder) {
+	fmt.Print(`TGE command line tool creates, builds and packages TGE applications.
+
+To install:
+    $ go install github.com/thommil/tge-cli@latest
+
+Usage:
+    tge-cli command [flags] [arguments]
+
+Available commands:
+`)
+	for _, c := range commands {
+		fmt.Printf("    %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Println("\nHelp topics:")
+	for _, t := range helpTopics {
+		fmt.Printf("    %-11s %s\n", t.name, t.summary)
+	}
+	fmt.Print(`
+Flags can be placed anywhere after the command, use 'tge-cli help command' for
+help on a command and 'tge-cli help topic' for a topic.
+
+Other commands are run by plugins: 'tge-cli NAME args...' runs the tge-cli-NAME
+executable found in the project .tge/bin folder or in PATH. Plugins are run with
+the project context in TGE_PROJECT_PATH, TGE_PROJECT_PACKAGE, TGE_PROJECT_DIST,
+TGE_PROJECT_TGE_VERSION, TGE_PROJECT_TGE_ROOT, GOPATH and GOBIN, the tge-cli
+executable is available in TGE_CLI.
+
+` + exitCodesHelp + "\n")
+	plugins := listPlugins(findProject(builder.cwd))
+	if len(plugins) == 0 {
+		return
+	}
+	fmt.Println("\nAvailable plugins:")
+	for _, p := range plugins {
+		fmt.Printf("    %-11s %s\n", p.name, p.path)
+	}
+}
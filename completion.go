@@ -0,0 +1,255 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var completionCommand = &command{
+	name:        "completion",
+	summary:     "Generate shell completion scripts",
+	synopsis:    []string{"bash|zsh|fish"},
+	subcommands: []string{"bash", "zsh", "fish"},
+	help: `The script is printed on standard output, to enable completion:
+    bash    source <(tge-cli completion bash)            (in ~/.bashrc)
+    zsh     tge-cli completion zsh > "${fpath[1]}/_tge-cli"
+    fish    tge-cli completion fish > ~/.config/fish/completions/tge-cli.fish
+
+Commands, flags, targets and profiles are completed along with the plugins
+found in PATH.`,
+	run: doCompletion,
+}
+
+// completionFlag describes a flag for completion scripts
+type completionFlag struct {
+	name        string
+	description string
+	isBool      bool
+	values      []string
+}
+
+// completionFlags returns the flags of c sorted by name
+func (c *command) completionFlags() []completionFlag {
+	var flags []completionFlag
+	c.flagSet().VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		boolFlag, isBool := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:        f.Name,
+			description: shortDescription(usage),
+			isBool:      isBool && boolFlag.IsBoolFlag(),
+			values:      c.completions[f.Name],
+		})
+	})
+	return flags
+}
+
+// shortDescription returns the first clause of a flag usage
+func shortDescription(usage string) string {
+	usage = strings.Join(strings.Fields(usage), " ")
+	depth := 0
+	for i, r := range usage {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',', '.', ':':
+			if depth == 0 {
+				return usage[:i]
+			}
+		}
+	}
+	return usage
+}
+
+func doCompletion(builder Builder, fs *flag.FlagSet, args []string) {
+	if len(args) == 0 {
+		printCommandHelp("completion")
+		return
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion())
+	case "zsh":
+		fmt.Print(zshCompletion())
+	case "fish":
+		fmt.Print(fishCompletion())
+	default:
+		fail(newError(errUsage, nil, "unsupported shell '%s' (bash, zsh or fish)", args[0]))
+	}
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// helpNames returns the commands and topics completed by the help command
+func helpNames() []string {
+	names := commandNames()
+	for _, t := range helpTopics {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+func bashCompletion() string {
+	var script strings.Builder
+	script.WriteString(`# bash completion for tge-cli, generated by 'tge-cli completion bash'
+_tge_cli() {
+    local cur prev words
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        local plugins=$(compgen -c tge-cli- | sed 's/^tge-cli-//')
+        COMPREPLY=($(compgen -W "` + strings.Join(commandNames(), " ") + ` $plugins" -- "$cur"))
+        return
+    fi
+    local flags="" subcommands=""
+    case "${COMP_WORDS[1]}" in
+`)
+	for _, c := range commands {
+		flags := c.completionFlags()
+		fmt.Fprintf(&script, "        %s)\n", c.name)
+		var cases []string
+		var names []string
+		for _, f := range flags {
+			names = append(names, "-"+f.name)
+			switch {
+			case len(f.values) == 0:
+			case f.values[0] == dirsCompletion[0]:
+				cases = append(cases, fmt.Sprintf("                -%s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;", f.name))
+			default:
+				cases = append(cases, fmt.Sprintf("                -%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;", f.name, strings.Join(f.values, " ")))
+			}
+		}
+		if len(cases) > 0 {
+			fmt.Fprintf(&script, "            case \"$prev\" in\n%s\n            esac\n", strings.Join(cases, "\n"))
+		}
+		if c.name == "help" {
+			fmt.Fprintf(&script, "            subcommands=\"%s\"\n", strings.Join(helpNames(), " "))
+		} else if len(c.subcommands) > 0 {
+			fmt.Fprintf(&script, "            subcommands=\"%s\"\n", strings.Join(c.subcommands, " "))
+		}
+		fmt.Fprintf(&script, "            flags=\"%s\"\n            ;;\n", strings.Join(names, " "))
+	}
+	script.WriteString(`    esac
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [ -n "$subcommands" ] && [ "$COMP_CWORD" -eq 2 ]; then
+        COMPREPLY=($(compgen -W "$subcommands" -- "$cur"))
+    else
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+complete -o filenames -F _tge_cli tge-cli
+`)
+	return script.String()
+}
+
+// zshEscape escapes a description for _arguments and _describe specs
+func zshEscape(value string) string {
+	return strings.NewReplacer(`'`, `'\''`, "[", `\[`, "]", `\]`).Replace(value)
+}
+
+func zshCompletion() string {
+	var script strings.Builder
+	script.WriteString(`#compdef tge-cli
+# zsh completion for tge-cli, generated by 'tge-cli completion zsh'
+_tge_cli() {
+    local -a tge_commands plugins
+    tge_commands=(
+`)
+	for _, c := range commands {
+		fmt.Fprintf(&script, "        '%s:%s'\n", c.name, zshEscape(c.summary))
+	}
+	script.WriteString(`    )
+    if (( CURRENT == 2 )); then
+        plugins=(${${(k)commands[(I)tge-cli-*]}#tge-cli-})
+        _describe -t commands 'tge-cli command' tge_commands
+        (( ${#plugins} )) && compadd -X plugins -a plugins
+        return
+    fi
+    local cmd=${words[2]}
+    shift words
+    (( CURRENT-- ))
+    case $cmd in
+`)
+	for _, c := range commands {
+		fmt.Fprintf(&script, "        %s)\n            _arguments \\\n", c.name)
+		for _, f := range c.completionFlags() {
+			spec := fmt.Sprintf("-%s[%s]", f.name, zshEscape(f.description))
+			switch {
+			case f.isBool:
+			case len(f.values) == 0:
+				spec += ":" + f.name + ": "
+			case f.values[0] == dirsCompletion[0]:
+				spec += ":" + f.name + ":_files -/"
+			default:
+				spec += fmt.Sprintf(":%s:(%s)", f.name, strings.Join(f.values, " "))
+			}
+			fmt.Fprintf(&script, "                '%s' \\\n", spec)
+		}
+		switch {
+		case c.name == "help":
+			fmt.Fprintf(&script, "                '1:command:(%s)'\n", strings.Join(helpNames(), " "))
+		case len(c.subcommands) > 0:
+			fmt.Fprintf(&script, "                '1:command:(%s)' \\\n                '*:file:_files'\n", strings.Join(c.subcommands, " "))
+		default:
+			script.WriteString("                '*:file:_files'\n")
+		}
+		script.WriteString("            ;;\n")
+	}
+	script.WriteString(`    esac
+}
+_tge_cli "$@"
+`)
+	return script.String()
+}
+
+// fishEscape escapes a value in single quotes
+func fishEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
+
+func fishCompletion() string {
+	var script strings.Builder
+	script.WriteString(`# fish completion for tge-cli, generated by 'tge-cli completion fish'
+complete -c tge-cli -f
+complete -c tge-cli -n __fish_use_subcommand -a '(complete -C"tge-cli-" | string replace -r "^tge-cli-(\S+).*" "\$1")' -d plugin
+`)
+	for _, c := range commands {
+		fmt.Fprintf(&script, "complete -c tge-cli -n __fish_use_subcommand -a %s -d '%s'\n", c.name, fishEscape(c.summary))
+	}
+	for _, c := range commands {
+		condition := fmt.Sprintf("'__fish_seen_subcommand_from %s'", c.name)
+		for _, f := range c.completionFlags() {
+			line := fmt.Sprintf("complete -c tge-cli -n %s -o %s -d '%s'", condition, f.name, fishEscape(f.description))
+			switch {
+			case f.isBool:
+			case len(f.values) == 0:
+				line += " -r"
+			case f.values[0] == dirsCompletion[0]:
+				line += " -x -a '(__fish_complete_directories)'"
+			default:
+				line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.values, " "))
+			}
+			script.WriteString(line + "\n")
+		}
+		switch {
+		case c.name == "help":
+			fmt.Fprintf(&script, "complete -c tge-cli -n %s -a '%s'\n", condition, strings.Join(helpNames(), " "))
+		case len(c.subcommands) > 0:
+			fmt.Fprintf(&script, "complete -c tge-cli -n %s -a '%s'\n", condition, strings.Join(c.subcommands, " "))
+		}
+		if c.name != "help" && c.name != "completion" {
+			fmt.Fprintf(&script, "complete -c tge-cli -n %s -a '(__fish_complete_path (commandline -ct))'\n", condition)
+		}
+	}
+	return script.String()
+}
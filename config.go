@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const userConfigPath = "tge-cli"
+const userConfigFile = "config.json"
+const envPrefix = "TGE_"
+
+// setting describes a configuration key, its value is resolved from (by
+// increasing priority) defaults, user config, project manifest, TGE_*
+// environment variables and flags.
+type setting struct {
+	key          string
+	defaultValue string
+	description  string
+}
+
+var settings = []setting{
+	{"offline", "false", "never access network, TGE is resolved locally"},
+	{"tge-path", "", "local TGE checkout"},
+	{"tge-version", "", "TGE version to use (tag, commit or pseudo-version)"},
+	{"verbose", "false", "verbose output for debugging"},
+	{"target", "desktop", "default build target"},
+	{"profile", releaseProfile, "default build profile"},
+	{"compiler", goCompiler, "compiler of browser target (go or tinygo)"},
+	{"dist", distPath, "folder where applications are generated, relative to workspace"},
+	{"gopath", "", "GOPATH used by go commands, default from go env"},
+	{"tools.gomobile", "", "gomobile binary, default from PATH or GOBIN"},
+	{"tools.tinygo", "", "tinygo binary, default from PATH"},
+	{"tools.appimagetool", "", "appimagetool binary, default from PATH"},
+}
+
+// flagAliases maps flag names to setting keys when they differ
+var flagAliases = map[string]string{
+	"v": "verbose",
+}
+
+type configValue struct {
+	value  string
+	origin string
+}
+
+// Config holds the effective settings along with their origin
+type Config struct {
+	values map[string]configValue
+}
+
+// envName returns the environment variable overriding a setting key
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(strings.TrimPrefix(key, "tge-")))
+}
+
+// userConfigFilePath returns the location of the user configuration file
+func userConfigFilePath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, userConfigPath, userConfigFile)
+}
+
+// loadConfig resolves the settings of the workspace at packagePath, flags are
+// the values set on command line.
+func loadConfig(packagePath string, flags map[string]string) (*Config, *Manifest, error) {
+	config := &Config{values: map[string]configValue{}}
+
+	for _, s := range settings {
+		config.values[s.key] = configValue{s.defaultValue, "default"}
+	}
+
+	if path := userConfigFilePath(); path != "" {
+		userConfig, err := loadManifestFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		config.merge(userConfig.settings(filepath.Dir(path)), fmt.Sprintf("user:%s", path))
+	}
+
+	manifest, err := loadManifest(packagePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	config.merge(manifest.settings(packagePath), fmt.Sprintf("project:%s", filepath.Join(packagePath, manifestFile)))
+
+	cwd, _ := os.Getwd()
+	for _, s := range settings {
+		if value, found := os.LookupEnv(envName(s.key)); found {
+			config.set(s.key, resolveSettingPath(s.key, cwd, value), fmt.Sprintf("env:%s", envName(s.key)))
+		}
+	}
+
+	for key, value := range flags {
+		config.set(key, resolveSettingPath(key, cwd, value), "flag")
+	}
+
+	for _, s := range settings {
+		if s.defaultValue == "false" || s.defaultValue == "true" {
+			if _, err := strconv.ParseBool(config.get(s.key)); err != nil {
+				kind := errProject
+				if config.values[s.key].origin == "flag" {
+					kind = errUsage
+				}
+				return nil, nil, newError(kind, nil, "invalid boolean value '%s' for %s (%s)", config.get(s.key), s.key, config.values[s.key].origin)
+			}
+		}
+	}
+
+	return config, manifest, nil
+}
+
+// resolveSettingPath makes path settings absolute
+func resolveSettingPath(key string, basePath string, value string) string {
+	switch {
+	case value == "":
+		return value
+	case key == "tge-path" || key == "dist":
+		return resolvePath(basePath, value)
+	case strings.HasPrefix(key, "tools.") && strings.ContainsAny(value, `/\`):
+		return resolvePath(basePath, value)
+	}
+	return value
+}
+
+func (config *Config) merge(values map[string]string, origin string) {
+	for key, value := range values {
+		config.set(key, value, origin)
+	}
+}
+
+func (config *Config) set(key string, value string, origin string) {
+	config.values[key] = configValue{value, origin}
+}
+
+func (config *Config) get(key string) string {
+	return config.values[key].value
+}
+
+func (config *Config) getBool(key string) bool {
+	value, _ := strconv.ParseBool(config.get(key))
+	return value
+}
+
+// flagSettings returns the settings explicitly set on command line among the
+// given flag names.
+func flagSettings(fs *flag.FlagSet, names ...string) map[string]string {
+	values := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				key := name
+				if alias, found := flagAliases[name]; found {
+					key = alias
+				}
+				values[key] = f.Value.String()
+			}
+		}
+	})
+	return values
+}
+
+// loadConfig resolves the settings of the workspace at packagePath and applies
+// them to the builder.
+func (builder *Builder) loadConfig(packagePath string) error {
+	config, manifest, err := loadConfig(packagePath, builder.flags)
+	if err != nil {
+		return err
+	}
+	builder.config = config
+	builder.manifest = manifest
+
+	builder.offline = config.getBool("offline")
+	builder.verbose = config.getBool("verbose")
+	builder.tgePath = config.get("tge-path")
+	builder.tgeVersion = config.get("tge-version")
+	return nil
+}
+
+var configCommand = &command{
+	name:        "config",
+	summary:     "Show the effective configuration and its origin",
+	synopsis:    []string{"list [-show-origin] [packagePath]"},
+	subcommands: []string{"list"},
+	help: `Settings are resolved by layers, each one overriding the previous:
+    default     built-in defaults
+    user        user configuration file ($CONFIG_DIR/tge-cli/config.json)
+    project     project manifest (tge.json in packagePath)
+    env         TGE_* environment variables (ex: TGE_OFFLINE, TGE_PATH, TGE_VERSION,
+                TGE_TOOLS_GOMOBILE)
+    flag        command line flags
+
+User configuration and project manifest share the same JSON format:
+    {
+        "offline": false,
+        "tgePath": "../tge",
+        "tgeVersion": "v0.1.0",
+        "verbose": false,
+        "target": "desktop",
+        "profile": "release",
+        "dist": "dist",
+        "gopath": "/home/me/go",
+        "tools": {
+            "gomobile": "/usr/local/bin/gomobile"
+        }
+    }`,
+	notes: settingsHelp(),
+	flags: func(fs *flag.FlagSet) {
+		fs.Bool("show-origin", false, "prints the origin of each setting (default, user:FILE, project:FILE,\nenv:VARIABLE or flag)")
+	},
+	run: doConfig,
+}
+
+// settingsHelp lists the available settings and their environment variables
+func settingsHelp() string {
+	lines := []string{"Available settings:"}
+	for _, s := range settings {
+		lines = append(lines, fmt.Sprintf("    %-22s %-25s %s", s.key, envName(s.key), s.description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func doConfig(builder Builder, fs *flag.FlagSet, args []string) {
+	if len(args) == 0 || args[0] != "list" {
+		printCommandHelp("config")
+		return
+	}
+
+	packagePath := builder.cwd
+	if len(args) > 1 {
+		packagePath = resolvePath(builder.cwd, args[1])
+	}
+
+	config, _, err := loadConfig(packagePath, nil)
+	if err != nil {
+		fail(err)
+	}
+
+	keys := make([]string, 0, len(config.values))
+	for key := range config.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if flagBool(fs, "show-origin") {
+			fmt.Printf("%-40s %s=%s\n", config.values[key].origin, key, config.values[key].value)
+		} else {
+			fmt.Printf("%s=%s\n", key, config.values[key].value)
+		}
+	}
+}
@@ -1,17 +1,32 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
-const tgeVersion = "master"
-const tgeLocalGoPath = ".tge"
+// cliVersion is the version of tge-cli, set at link time with
+// -ldflags "-X main.cliVersion=vX.Y.Z" or read from the module build info
+var cliVersion = "master"
+
+const tgeDefaultVersion = "latest"
+const tgeLocalVersion = "v0.0.0-00010101000000-000000000000"
+const tgeLocalPath = ".tge"
 const tgeTemplatePath = "template"
 
+// tgeTemplatesBasePath is the folder of the templates copied in the project,
+// it is committed with the project as merge base of upgrades
+const tgeTemplatesBasePath = ".tge-templates"
+const tgeLegacyTemplatesBasePath = "templates"
+const tgeLocalModFile = "go.local.mod"
+
+var tgeTargets = []string{"android", "ios", "browser", "darwin", "windows", "linux"}
+
 var tgePackageName = "github.com/thommil/tge"
 
 const distPath = "dist"
@@ -23,20 +38,48 @@ type Builder struct {
 	packageName string
 	packagePath string
 	goPath      string
+	goBin       string
+	goModCache  string
+	goRoot      string
 	tgeRootPath string
 	verbose     bool
+	offline     bool
+	tgePath     string
+	tgeVersion  string
+	manifest    *Manifest
+	config      *Config
+	flags       map[string]string
+
+	tgeProjectVersion string
+	// modFile is the go.mod copy used instead of the project one, if any
+	modFile string
+
+	//init
+	appName  string
+	bundleID string
+	author   string
+	here     bool
+	merge    bool
+	force    bool
+
+	stagingPath string
 
 	//build
 	target      string
-	devMode     bool
+	profile     buildProfile
 	assetsPath  string
 	distPath    string
+	buildFlags  BuildFlags
 	programName string
+	pwa         bool
+	singleFile  bool
+	// packageFormat is the installable package format of Linux target
+	packageFormat string
 }
 
 func createBuilder() Builder {
 	if err := checkGoVersion(); err != nil {
-		panic(err)
+		fail(err)
 	}
 
 	builder := Builder{}
@@ -46,75 +89,353 @@ func createBuilder() Builder {
 }
 
 // Builder common
-func (builder *Builder) installTGE() error {
-	builder.goPath = os.Getenv("GOPATH")
-	if builder.goPath == "" {
-		builder.goPath = filepath.Join(builder.packagePath, tgeLocalGoPath)
+func (builder *Builder) openWorkspace(packagePath string) error {
+	if err := builder.readWorkspace(packagePath); err != nil {
+		return err
+	}
+
+	if err := os.Chdir(builder.packagePath); err != nil {
+		return err
 	}
 
-	cmd := exec.Command("go", "list", "-e", "-f", "{{.Dir}}", tgePackageName)
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("GOPATH=%s", builder.goPath),
-	)
-	if output, err := cmd.Output(); err != nil {
-		return fmt.Errorf("failed to analyze GOPATH %s: %s", builder.goPath, err)
+	return builder.installTGE()
+}
+
+// readWorkspace sets the package path, names and configuration of the project
+// without installing TGE
+func (builder *Builder) readWorkspace(packagePath string) error {
+	if !filepath.IsAbs(packagePath) {
+		builder.packagePath = filepath.Join(builder.cwd, packagePath)
 	} else {
-		builder.tgeRootPath = strings.TrimSpace(string(output))
-	}
-
-	if builder.tgeRootPath == "" {
-		if _, err := os.Stat(filepath.Join(builder.packagePath, "go.mod")); os.IsNotExist(err) {
-			log("NOTICE", fmt.Sprintf("Initializing '%s' module", builder.packageName))
-			cmd := exec.Command("go", "mod", "init", builder.packageName)
-			cmd.Env = append(os.Environ(),
-				fmt.Sprintf("GOPATH=%s", builder.goPath),
-			)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("failed to initialze workspace")
-			}
-		}
+		builder.packagePath = packagePath
+	}
 
-		log("NOTICE", fmt.Sprintf("Installing TGE in %s", builder.goPath))
-		log("NOTICE", fmt.Sprintf("Using GOPATH %s (set it for DEV)", builder.goPath))
-		cmd := exec.Command("go", "get", "-u")
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("GOPATH=%s", builder.goPath),
-		)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to install TGE")
+	if _, err := os.Stat(builder.packagePath); os.IsNotExist(err) {
+		return newError(errProject, nil, "package path '%s' not found", builder.packagePath)
+	}
+
+	builder.programName = filepath.Base(builder.packagePath)
+	builder.packageName = readModulePath(filepath.Join(builder.packagePath, "go.mod"))
+	if builder.packageName == "" {
+		builder.packageName = builder.programName
+	}
+
+	if builder.config == nil {
+		return builder.loadConfig(builder.packagePath)
+	}
+	return nil
+}
+
+func (builder *Builder) installTGE() error {
+	if err := builder.loadGoEnv(); err != nil {
+		return err
+	}
+
+	if err := builder.initModule(); err != nil {
+		return err
+	}
+
+	if builder.tgePath != "" {
+		if builder.tgeVersion != "" {
+			return newError(errUsage, nil, "-tge-version can't be used with a local TGE checkout (%s)", builder.tgePath)
 		}
+		return builder.linkLocalTGE()
+	}
 
-		cmd = exec.Command("go", "list", "-e", "-f", "{{.Dir}}", tgePackageName)
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("GOPATH=%s", builder.goPath),
-		)
-		if output, err := cmd.Output(); err != nil {
-			return fmt.Errorf("failed to analyze GOPATH %s: %s", builder.goPath, err)
+	if err := builder.lookupTGE(); err != nil {
+		return err
+	}
+
+	// A pinned version already required by go.mod is not fetched again
+	pinned := builder.tgeVersion != "" && builder.tgeVersion != builder.tgeProjectVersion
+	if builder.tgeRootPath == "" || pinned {
+		version := builder.tgeVersion
+		if version == "" {
+			version = tgeDefaultVersion
+		}
+		if builder.offline {
+			log("NOTICE", fmt.Sprintf("Installing TGE %s from modules cache %s (offline)", version, builder.goModCache))
 		} else {
-			builder.tgeRootPath = strings.TrimSpace(string(output))
+			log("NOTICE", fmt.Sprintf("Installing TGE %s", version))
+		}
+		cmd := exec.Command("go", "get", fmt.Sprintf("%s@%s", tgePackageName, version))
+		cmd.Env = builder.environ()
+		if err := runCommand(cmd); err != nil {
+			if builder.offline {
+				return newError(errProject, nil, "offline mode: TGE %s not found in vendor directory or modules cache %s, run 'go mod download %s@%s' while online or use -tge-path", version, builder.goModCache, tgePackageName, version)
+			}
+			return newError(errProject, err, "failed to install TGE %s", version)
+		}
+
+		if err := builder.lookupTGE(); err != nil {
+			return err
 		}
 
 		if builder.tgeRootPath == "" {
-			return fmt.Errorf("failed to install TGE, try manually using 'go get %s'", tgePackageName)
+			return newError(errProject, nil, "failed to install TGE, try manually using 'go get %s@%s'", tgePackageName, version)
+		}
+	}
+
+	if warning := checkTGECompatibility(cliVersion, builder.tgeProjectVersion); warning != "" {
+		log("WARNING", warning)
+	}
+
+	return nil
+}
+
+// moduleInfo is the output of 'go list -m -json'
+type moduleInfo struct {
+	Path    string
+	Version string
+	Dir     string
+	Replace *moduleInfo
+	Error   *struct {
+		Err string
+	}
+}
+
+// lookupTGE sets TGE root path and version if TGE is required by the workspace
+// module, from the vendor directory or the modules cache (read-only).
+func (builder *Builder) lookupTGE() error {
+	return builder.lookupTGEModule(true)
+}
+
+// lookupTGEModule implements lookupTGE, the module is downloaded and looked
+// up again once if it is missing from the modules cache.
+func (builder *Builder) lookupTGEModule(download bool) error {
+	builder.tgeRootPath = ""
+
+	cmd := exec.Command("go", "list", "-m", "-e", "-json", tgePackageName)
+	cmd.Env = builder.environ()
+	output, err := cmd.Output()
+	if err != nil {
+		return newError(errProject, err, "failed to analyze module %s", builder.packageName)
+	}
+	info := moduleInfo{}
+	if err = json.Unmarshal(output, &info); err != nil {
+		return newError(errProject, err, "failed to analyze module %s", builder.packageName)
+	}
+	if info.Error != nil || info.Version == "" {
+		return nil
+	}
+
+	builder.tgeProjectVersion = info.Version
+	if info.Replace != nil {
+		builder.tgeProjectVersion = info.Replace.Path
+		if info.Replace.Version != "" {
+			builder.tgeProjectVersion = fmt.Sprintf("%s@%s", info.Replace.Path, info.Replace.Version)
+		}
+		info.Dir = info.Replace.Dir
+	}
+
+	vendorPath := filepath.Join(builder.packagePath, "vendor", filepath.FromSlash(tgePackageName))
+	if _, err := os.Stat(filepath.Join(builder.packagePath, "vendor", "modules.txt")); err == nil {
+		if _, err = os.Stat(vendorPath); err == nil {
+			builder.tgeRootPath = vendorPath
+			return nil
+		}
+	}
+
+	if info.Dir == "" {
+		if !download {
+			return newError(errProject, nil, "TGE %s not found in modules cache %s after download (run 'go mod vendor' to refresh the vendor directory, or remove -mod=vendor from GOFLAGS)", builder.tgeProjectVersion, builder.goModCache)
+		}
+		cmd = exec.Command("go", "mod", "download", tgePackageName)
+		cmd.Env = builder.environ()
+		if err := runCommand(cmd); err != nil {
+			if builder.offline {
+				return newError(errProject, nil, "offline mode: TGE %s not found in modules cache %s", builder.tgeProjectVersion, builder.goModCache)
+			}
+			return newError(errProject, err, "failed to download TGE %s", builder.tgeProjectVersion)
 		}
+		return builder.lookupTGEModule(false)
 	}
 
-	if err := filepath.Walk(builder.tgeRootPath, func(p string, info os.FileInfo, err error) error {
-		if err = os.Chmod(p, info.Mode()|os.FileMode(0222)); err != nil {
+	builder.tgeRootPath = info.Dir
+	return nil
+}
+
+// linkLocalTGE wires a local TGE checkout to the workspace module using a
+// replace directive in a go.mod copy of the .tge folder, passed to go commands
+// with -modfile. The project go.mod is left untouched and nothing is
+// downloaded.
+func (builder *Builder) linkLocalTGE() error {
+	tgePath, err := filepath.Abs(builder.tgePath)
+	if err != nil {
+		return err
+	}
+	if modulePath := readModulePath(filepath.Join(tgePath, "go.mod")); modulePath != tgePackageName {
+		return newError(errProject, nil, "TGE checkout not found in %s (missing go.mod with module %s)", tgePath, tgePackageName)
+	}
+	if _, err := os.Stat(filepath.Join(tgePath, tgeTemplatePath)); os.IsNotExist(err) {
+		return newError(errProject, nil, "invalid TGE checkout %s: '%s' folder not found", tgePath, tgeTemplatePath)
+	}
+
+	log("NOTICE", fmt.Sprintf("Using local TGE checkout %s", tgePath))
+	modFile := filepath.Join(builder.packagePath, tgeLocalPath, tgeLocalModFile)
+	if err := os.MkdirAll(filepath.Dir(modFile), os.ModeDir|0755); err != nil {
+		return err
+	}
+	goMod, err := ioutil.ReadFile(filepath.Join(builder.packagePath, "go.mod"))
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(modFile, goMod, 0644); err != nil {
+		return err
+	}
+	sumFile := strings.TrimSuffix(modFile, ".mod") + ".sum"
+	if goSum, found := readOptionalFile(filepath.Join(builder.packagePath, "go.sum")); found {
+		if err := ioutil.WriteFile(sumFile, goSum, 0644); err != nil {
 			return err
 		}
-		return nil
-	}); err != nil {
-		return fmt.Errorf("failed to set read permission on %s: %s", builder.tgeRootPath, err)
+	} else {
+		os.Remove(sumFile)
 	}
+	cmd := exec.Command("go", "mod", "edit",
+		"-modfile="+modFile,
+		fmt.Sprintf("-require=%s@%s", tgePackageName, tgeLocalVersion),
+		fmt.Sprintf("-replace=%s=%s", tgePackageName, tgePath))
+	cmd.Env = builder.environ()
+	if err := runCommand(cmd); err != nil {
+		return newError(errProject, err, "failed to add replace directive for %s", tgePath)
+	}
+
+	builder.modFile = modFile
+	builder.tgeRootPath = tgePath
+	builder.tgeProjectVersion = tgePath
+	return nil
+}
+
+// initModule creates the go.mod file of the workspace if missing.
+func (builder *Builder) initModule() error {
+	if _, err := os.Stat(filepath.Join(builder.packagePath, "go.mod")); os.IsNotExist(err) {
+		log("NOTICE", fmt.Sprintf("Initializing '%s' module", builder.packageName))
+		cmd := exec.Command("go", "mod", "init", builder.packageName)
+		cmd.Env = builder.environ()
+		if err := runCommand(cmd); err != nil {
+			return newError(errProject, err, "failed to initialize workspace module")
+		}
+	}
+	return nil
+}
 
+// loadGoEnv retrieves GOPATH, GOBIN and GOMODCACHE from go env
+func (builder *Builder) loadGoEnv() error {
+	cmd := exec.Command("go", "env", "GOPATH", "GOBIN", "GOMODCACHE", "GOROOT")
+	cmd.Env = builder.environ()
+	output, err := cmd.Output()
+	if err != nil {
+		return newError(errToolchain, err, "'go env' failed")
+	}
+	values := strings.Split(strings.TrimRight(string(output), "\r\n"), "\n")
+	if len(values) != 4 {
+		return fmt.Errorf("unexpected 'go env' output: %s", output)
+	}
+	builder.goPath = strings.TrimSpace(values[0])
+	builder.goBin = strings.TrimSpace(values[1])
+	builder.goModCache = strings.TrimSpace(values[2])
+	builder.goRoot = strings.TrimSpace(values[3])
+	if builder.goBin == "" {
+		builder.goBin = filepath.Join(filepath.SplitList(builder.goPath)[0], "bin")
+	}
 	return nil
 }
 
+// environ returns the environment used by go commands, in offline mode the
+// modules cache is used as proxy so network is never accessed.
+func (builder *Builder) environ(extra ...string) []string {
+	env := os.Environ()
+	if builder.config != nil && builder.config.get("gopath") != "" {
+		env = append(env, fmt.Sprintf("GOPATH=%s", builder.config.get("gopath")))
+	}
+	if builder.offline && builder.goModCache != "" {
+		modCacheURL := filepath.ToSlash(filepath.Join(builder.goModCache, "cache", "download"))
+		if !strings.HasPrefix(modCacheURL, "/") {
+			modCacheURL = "/" + modCacheURL
+		}
+		env = append(env,
+			fmt.Sprintf("GOPROXY=file://%s", modCacheURL),
+			"GOSUMDB=off",
+		)
+	}
+	if builder.modFile != "" {
+		env = append(env, fmt.Sprintf("GOFLAGS=%s -modfile=%s", os.Getenv("GOFLAGS"), builder.modFile))
+	}
+	return append(env, extra...)
+}
+
+// goModFile returns the go.mod file used by go commands
+func (builder *Builder) goModFile() string {
+	if builder.modFile != "" {
+		return builder.modFile
+	}
+	return filepath.Join(builder.packagePath, "go.mod")
+}
+
+// resourcesBasePath returns the folder storing the pristine template copied in
+// the target resources folder
+func (builder *Builder) resourcesBasePath(target string) string {
+	return filepath.Join(builder.packagePath, tgeTemplatesBasePath, target)
+}
+
+// legacyResourcesBasePath returns the folder of the pristine template in
+// projects created before it was committed (.tge/templates, ignored by git)
+func (builder *Builder) legacyResourcesBasePath(target string) string {
+	return filepath.Join(builder.packagePath, tgeLocalPath, tgeLegacyTemplatesBasePath, target)
+}
+
+// checkTargets fails if one of targets is not a TGE target
+func checkTargets(targets []string) error {
+	for _, target := range targets {
+		if indexOf(tgeTargets, target) < 0 {
+			return newError(errUsage, nil, "unknown target '%s' (%s)", target, strings.Join(tgeTargets, ", "))
+		}
+	}
+	return nil
+}
+
+// saveResourcesBase stores the current TGE template of target, it is used as
+// merge base on upgrade
+func (builder *Builder) saveResourcesBase(target string) error {
+	basePath := builder.resourcesBasePath(target)
+	if err := removeAll(basePath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(basePath, os.ModeDir|0755); err != nil {
+		return err
+	}
+	if _, err := renderTemplateDir(filepath.Join(builder.tgeRootPath, tgeTemplatePath, target), basePath, builder.templateVars(), true); err != nil {
+		return err
+	}
+	return removeAll(builder.legacyResourcesBasePath(target))
+}
+
+// readModulePath returns the module path declared in a go.mod file
+func readModulePath(goModPath string) string {
+	content, err := ioutil.ReadFile(goModPath)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), "\"")
+		}
+	}
+	return ""
+}
+
+// removeAll removes path recursively, including read-only folders
+func removeAll(path string) error {
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(p, info.Mode()|0700)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
 // LOGS
 func log(state string, msg string) {
 	if state == "SUCCESS" {
@@ -128,25 +449,19 @@ func log(state string, msg string) {
 func checkGoVersion() error {
 	gobin, err := exec.LookPath("go")
 	if err != nil {
-		err = fmt.Errorf("go not found")
-		log("ERROR", err.Error())
-		return err
+		return newError(errToolchain, nil, "go not found")
 	}
 	goVersionOut, err := exec.Command(gobin, "version").CombinedOutput()
 	if err != nil {
-		err = fmt.Errorf("'go version' failed: %v, %s", err, goVersionOut)
-		log("ERROR", err.Error())
-		return err
+		return newError(errToolchain, err, "'go version' failed: %s", strings.TrimSpace(string(goVersionOut)))
 	}
 	var minor int
 	if _, err := fmt.Sscanf(string(goVersionOut), "go version go1.%d", &minor); err != nil {
 		// Ignore unknown versions; it's probably a devel version.
 		return nil
 	}
-	if minor < 12 {
-		err = fmt.Errorf("Go 1.12 or newer is required")
-		log("ERROR", err.Error())
-		return err
+	if minor < 16 {
+		return newError(errToolchain, nil, "Go 1.16 or newer is required")
 	}
 	return nil
 }
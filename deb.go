@@ -0,0 +1,328 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// debVersionPattern matches Debian versions, [epoch:]upstream[-revision]
+var debVersionPattern = regexp.MustCompile(`^([0-9]+:)?[0-9][A-Za-z0-9.+~-]*$`)
+
+// debNamePattern matches Debian package names
+var debNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+$`)
+
+// debMaintainerPattern matches the Maintainer field, Name <email>
+var debMaintainerPattern = regexp.MustCompile(`^[^<>]+ <[^<>@ ]+@[^<>@ ]+>$`)
+
+// debMaintainer returns maintainer in 'Name <email>' form, the email is taken
+// from 'git config user.email' if missing.
+func debMaintainer(maintainer string) (string, error) {
+	if !strings.Contains(maintainer, "<") {
+		if output, err := exec.Command("git", "config", "user.email").Output(); err == nil {
+			if email := strings.TrimSpace(string(output)); email != "" {
+				maintainer = fmt.Sprintf("%s <%s>", strings.TrimSpace(maintainer), email)
+			}
+		}
+	}
+	if !debMaintainerPattern.MatchString(maintainer) {
+		return "", newError(errProject, nil, "invalid Debian package maintainer '%s', set package.maintainer to 'Name <email>' in %s", maintainer, manifestFile)
+	}
+	return maintainer, nil
+}
+
+// DebSettings holds the settings of Debian packages
+type DebSettings struct {
+	// Depends lists the package dependencies (ex: libgl1, libc6 (>= 2.31))
+	Depends []string `json:"depends,omitempty"`
+	// Section is the archive section, default is games
+	Section string `json:"section,omitempty"`
+	// Priority is the package priority, default is optional
+	Priority string `json:"priority,omitempty"`
+	// Install is the binary location, games for /usr/games (default) or opt
+	// for /opt/<name>
+	Install string `json:"install,omitempty"`
+}
+
+// debArchs maps GOARCH to Debian architectures
+var debArchs = map[string]string{
+	"amd64":   "amd64",
+	"386":     "i386",
+	"arm64":   "arm64",
+	"arm":     "armhf",
+	"ppc64le": "ppc64el",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// tarball builds a gzipped tar archive, parent directories of files are
+// added automatically.
+type tarball struct {
+	buf     bytes.Buffer
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	modTime time.Time
+	dirs    map[string]bool
+	// size is the total size of files content
+	size int64
+	// md5sums lists the md5 of files content, in md5sum format
+	md5sums []string
+}
+
+func newTarball() *tarball {
+	t := &tarball{modTime: time.Now().Truncate(time.Second), dirs: map[string]bool{}}
+	t.gz, _ = gzip.NewWriterLevel(&t.buf, gzip.BestCompression)
+	t.tw = tar.NewWriter(t.gz)
+	return t
+}
+
+func (t *tarball) addDir(name string) error {
+	if name == "." || t.dirs[name] {
+		return nil
+	}
+	if err := t.addDir(path.Dir(name)); err != nil {
+		return err
+	}
+	t.dirs[name] = true
+	return t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "./" + name + "/",
+		Mode:     0755,
+		ModTime:  t.modTime,
+		Uname:    "root",
+		Gname:    "root",
+	})
+}
+
+// addFile adds a file at name, a slash separated path relative to the root
+func (t *tarball) addFile(name string, content []byte, mode int64) error {
+	if err := t.addDir(path.Dir(name)); err != nil {
+		return err
+	}
+	err := t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "./" + name,
+		Mode:     mode,
+		Size:     int64(len(content)),
+		ModTime:  t.modTime,
+		Uname:    "root",
+		Gname:    "root",
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = t.tw.Write(content); err != nil {
+		return err
+	}
+	t.size += int64(len(content))
+	t.md5sums = append(t.md5sums, fmt.Sprintf("%x  %s", md5.Sum(content), name))
+	return nil
+}
+
+// addTree adds the files of srcPath under name
+func (t *tarball) addTree(srcPath string, name string) error {
+	return filepath.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, _ := filepath.Rel(srcPath, p)
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		mode := int64(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		return t.addFile(path.Join(name, filepath.ToSlash(relPath)), content, mode)
+	})
+}
+
+func (t *tarball) bytes() ([]byte, error) {
+	if err := t.tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := t.gz.Close(); err != nil {
+		return nil, err
+	}
+	return t.buf.Bytes(), nil
+}
+
+// arFile is a member of an ar archive
+type arFile struct {
+	name    string
+	content []byte
+}
+
+// writeAr writes files in the common ar format used by Debian packages
+func writeAr(w io.Writer, modTime time.Time, files ...arFile) error {
+	if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if _, err := fmt.Fprintf(w, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", f.name, modTime.Unix(), 0, 0, 0100644, len(f.content)); err != nil {
+			return err
+		}
+		if _, err := w.Write(f.content); err != nil {
+			return err
+		}
+		if len(f.content)%2 != 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// debDescription formats a description for the control file, continuation
+// lines start with a space and empty lines are replaced by " ."
+func debDescription(summary string, description string) string {
+	lines := []string{summary}
+	if description != "" {
+		for _, line := range strings.Split(description, "\n") {
+			if line = strings.TrimRight(line, " \t"); line == "" {
+				line = "."
+			}
+			lines = append(lines, " "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// packageDeb writes a Debian package of the Linux application in dist, the
+// binary is installed in /usr/games or /opt/<name>, the assets in
+// /usr/share/<name> along with a desktop entry and hicolor icons.
+func (builder *Builder) packageDeb(binaryFile string) error {
+	metadata := builder.packageMetadata()
+	var settings DebSettings
+	if builder.manifest != nil {
+		settings = builder.manifest.Package.Deb
+	}
+	arch, found := debArchs[goArch()]
+	if !found {
+		return newError(errUsage, nil, "unsupported architecture '%s' for Debian package", goArch())
+	}
+	if settings.Section == "" {
+		settings.Section = "games"
+	}
+	if settings.Priority == "" {
+		settings.Priority = "optional"
+	}
+	var binaryPath string
+	switch settings.Install {
+	case "", "games":
+		binaryPath = path.Join("usr", "games", metadata.name)
+	case "opt":
+		binaryPath = path.Join("opt", metadata.name, metadata.name)
+	default:
+		return newError(errProject, nil, "invalid deb install '%s' (games or opt)", settings.Install)
+	}
+	sharePath := path.Join("usr", "share", metadata.name)
+	if !debNamePattern.MatchString(metadata.name) {
+		return newError(errProject, nil, "invalid Debian package name '%s', set package.name in the project manifest (at least 2 lowercase letters, digits, '+', '-' or '.', starting with a letter or digit)", metadata.name)
+	}
+	if !debVersionPattern.MatchString(metadata.version) {
+		return newError(errProject, nil, "invalid Debian package version '%s', it must start with a digit (ex: 1.0.0)", metadata.version)
+	}
+	maintainer, err := debMaintainer(metadata.maintainer)
+	if err != nil {
+		return err
+	}
+
+	debFile := fmt.Sprintf("%s_%s_%s.deb", metadata.name, metadata.version, arch)
+	log("NOTICE", fmt.Sprintf("Packaging %s", debFile))
+
+	// Data
+	data := newTarball()
+	binary, err := ioutil.ReadFile(filepath.Join(builder.distPath, binaryFile))
+	if err != nil {
+		return err
+	}
+	if err = data.addFile(binaryPath, binary, 0755); err != nil {
+		return err
+	}
+	if err = data.addTree(builder.assetsPath, path.Join(sharePath, assetsPath)); err != nil {
+		return err
+	}
+	entry := metadata.desktopEntry("/"+binaryPath, "/"+sharePath, builder.profile.console)
+	if err = validateDesktopEntry(entry); err != nil {
+		return newError(errProject, err, "invalid desktop entry")
+	}
+	if err = data.addFile(path.Join("usr", "share", "applications", metadata.name+".desktop"), []byte(entry), 0644); err != nil {
+		return err
+	}
+	if iconPath := builder.packageIcon(); iconPath != "" {
+		for _, size := range hicolorIconSizes {
+			icon, err := resizedPNG(iconPath, size)
+			if err != nil {
+				return fmt.Errorf("failed to generate icon: %s", err)
+			}
+			iconName := path.Join("usr", "share", "icons", "hicolor", fmt.Sprintf("%dx%d", size, size), "apps", metadata.name+".png")
+			if err = data.addFile(iconName, icon, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	dataContent, err := data.bytes()
+	if err != nil {
+		return err
+	}
+
+	// Control
+	fields := [][2]string{
+		{"Package", metadata.name},
+		{"Version", metadata.version},
+		{"Architecture", arch},
+		{"Maintainer", maintainer},
+		{"Installed-Size", fmt.Sprint((data.size + 1023) / 1024)},
+		{"Depends", strings.Join(settings.Depends, ", ")},
+		{"Section", settings.Section},
+		{"Priority", settings.Priority},
+		{"Homepage", metadata.homepage},
+		{"Description", debDescription(metadata.summary, metadata.description)},
+	}
+	var control strings.Builder
+	for _, field := range fields {
+		if field[1] != "" {
+			fmt.Fprintf(&control, "%s: %s\n", field[0], field[1])
+		}
+	}
+	sort.Strings(data.md5sums)
+	controlTar := newTarball()
+	controlTar.modTime = data.modTime
+	if err = controlTar.addFile("control", []byte(control.String()), 0644); err != nil {
+		return err
+	}
+	if err = controlTar.addFile("md5sums", []byte(strings.Join(data.md5sums, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	controlContent, err := controlTar.bytes()
+	if err != nil {
+		return err
+	}
+
+	// Archive
+	var deb bytes.Buffer
+	err = writeAr(&deb, data.modTime,
+		arFile{"debian-binary", []byte("2.0\n")},
+		arFile{"control.tar.gz", controlContent},
+		arFile{"data.tar.gz", dataContent},
+	)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(builder.distPath, debFile), deb.Bytes(), 0644)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAr parses an ar archive written by writeAr
+func readAr(t *testing.T, content []byte) []arFile {
+	t.Helper()
+	if !bytes.HasPrefix(content, []byte("!<arch>\n")) {
+		t.Fatalf("missing ar magic")
+	}
+	var files []arFile
+	for offset := 8; offset < len(content); {
+		if offset+60 > len(content) {
+			t.Fatalf("truncated ar header at %d", offset)
+		}
+		header := string(content[offset : offset+60])
+		if !strings.HasSuffix(header, "`\n") {
+			t.Fatalf("invalid ar header %q", header)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[48:58]))
+		if err != nil {
+			t.Fatalf("invalid ar size in %q", header)
+		}
+		offset += 60
+		files = append(files, arFile{strings.TrimSpace(header[:16]), content[offset : offset+size]})
+		offset += size + size%2
+	}
+	return files
+}
+
+func TestWriteAr(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []arFile
+	}{
+		{"empty", nil},
+		{"even sizes", []arFile{{"debian-binary", []byte("2.0\n")}, {"control.tar.gz", []byte("ab")}}},
+		{"odd sizes", []arFile{{"a", []byte("abc")}, {"b", []byte("d")}, {"c", []byte("efgh")}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeAr(&buf, time.Unix(1700000000, 0), test.files...); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len()%2 != 0 {
+				t.Errorf("ar archive size %d is not even", buf.Len())
+			}
+			if files := readAr(t, buf.Bytes()); !reflect.DeepEqual(files, test.files) {
+				t.Errorf("ar files = %q, want %q", files, test.files)
+			}
+		})
+	}
+}
+
+func TestTarball(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		entries []string
+	}{
+		{
+			name:    "root file",
+			files:   map[string]string{"control": "Package: demo\n"},
+			entries: []string{"./control"},
+		},
+		{
+			name:    "parent directories",
+			files:   map[string]string{"usr/games/demo": "binary", "usr/share/demo/assets/a.txt": "a"},
+			entries: []string{"./usr/", "./usr/games/", "./usr/games/demo", "./usr/share/", "./usr/share/demo/", "./usr/share/demo/assets/", "./usr/share/demo/assets/a.txt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tb := newTarball()
+			var size int64
+			var names []string
+			for name := range test.files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if err := tb.addFile(name, []byte(test.files[name]), 0644); err != nil {
+					t.Fatal(err)
+				}
+				size += int64(len(test.files[name]))
+			}
+			content, err := tb.bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tb.size != size {
+				t.Errorf("size = %d, want %d", tb.size, size)
+			}
+			if len(tb.md5sums) != len(test.files) {
+				t.Errorf("md5sums = %q, want %d entries", tb.md5sums, len(test.files))
+			}
+
+			gz, err := gzip.NewReader(bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := tar.NewReader(gz)
+			var entries []string
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				entries = append(entries, header.Name)
+				if header.Uname != "root" || header.Gname != "root" {
+					t.Errorf("%s owner = %s:%s, want root:root", header.Name, header.Uname, header.Gname)
+				}
+				if header.Typeflag == tar.TypeReg {
+					data, _ := ioutil.ReadAll(tr)
+					if want := test.files[strings.TrimPrefix(header.Name, "./")]; string(data) != want {
+						t.Errorf("%s content = %q, want %q", header.Name, data, want)
+					}
+				}
+			}
+			if !reflect.DeepEqual(entries, test.entries) {
+				t.Errorf("entries = %q, want %q", entries, test.entries)
+			}
+		})
+	}
+}
+
+func TestDebNamePattern(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"my-game", true},
+		{"game2", true},
+		{"libfoo++", true},
+		{"0ad", true},
+		{"a", false},
+		{"-game", false},
+		{"My-Game", false},
+		{"my_game", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if valid := debNamePattern.MatchString(test.name); valid != test.valid {
+			t.Errorf("debNamePattern.MatchString(%q) = %t, want %t", test.name, valid, test.valid)
+		}
+	}
+}
+
+func TestDebVersionPattern(t *testing.T) {
+	tests := []struct {
+		version string
+		valid   bool
+	}{
+		{"1.0.0", true},
+		{"0.1", true},
+		{"1:2.0-1", true},
+		{"1.0~rc1+git20240101", true},
+		{"v1.0.0", false},
+		{"", false},
+		{"1.0 beta", false},
+		{"1.0_1", false},
+	}
+	for _, test := range tests {
+		if valid := debVersionPattern.MatchString(test.version); valid != test.valid {
+			t.Errorf("debVersionPattern.MatchString(%q) = %t, want %t", test.version, valid, test.valid)
+		}
+	}
+}
+
+func TestDebMaintainer(t *testing.T) {
+	tests := []struct {
+		maintainer string
+		want       string
+		err        bool
+	}{
+		{"Jo Doe <jo@example.com>", "Jo Doe <jo@example.com>", false},
+		{"Jo Doe <jo>", "", true},
+		{"<jo@example.com>", "", true},
+	}
+	for _, test := range tests {
+		maintainer, err := debMaintainer(test.maintainer)
+		if (err != nil) != test.err || maintainer != test.want {
+			t.Errorf("debMaintainer(%q) = %q, %v, want %q, error %t", test.maintainer, maintainer, err, test.want, test.err)
+		}
+	}
+}
+
+func TestDebDescription(t *testing.T) {
+	tests := []struct {
+		summary     string
+		description string
+		want        string
+	}{
+		{"A game", "", "A game"},
+		{"A game", "First line\n\nLast line  ", "A game\n First line\n .\n Last line"},
+	}
+	for _, test := range tests {
+		if got := debDescription(test.summary, test.description); got != test.want {
+			t.Errorf("debDescription(%q, %q) = %q, want %q", test.summary, test.description, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells limits the size of the LCS table, larger files are handled as
+// a whole.
+const maxDiffCells = 25000000
+
+// splitLines splits content in lines, line endings are kept.
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		index := bytes.IndexByte(content, '\n')
+		if index < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:index+1]))
+		content = content[index+1:]
+	}
+	return lines
+}
+
+// isBinary returns true if content looks like a binary file.
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// matchLines computes the longest common subsequence of a and b, the returned
+// slice gives for each line of a the index of the matching line in b or -1.
+func matchLines(a []string, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	// Common prefix and suffix
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		matches[start] = start
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && a[endA-1] == b[endB-1] {
+		endA--
+		endB--
+		matches[endA] = endB
+	}
+
+	n, m := endA-start, endB-start
+	if n == 0 || m == 0 || (n+1)*(m+1) > maxDiffCells {
+		return matches
+	}
+
+	lengths := make([][]int32, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[start+i] == b[start+j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		if a[start+i] == b[start+j] {
+			matches[start+i] = start + j
+			i++
+			j++
+		} else if lengths[i+1][j] >= lengths[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return matches
+}
+
+// mergeLabels names the sides of a conflict.
+type mergeLabels struct {
+	ours   string
+	base   string
+	theirs string
+}
+
+// merge3 merges the changes from base to ours and from base to theirs, the
+// number of conflicts is returned along with the merged content where each
+// conflict is delimited with diff3 style markers.
+func merge3(base []byte, ours []byte, theirs []byte, labels mergeLabels) ([]byte, int) {
+	o, a, b := splitLines(base), splitLines(ours), splitLines(theirs)
+	matchA, matchB := matchLines(o, a), matchLines(o, b)
+
+	var out bytes.Buffer
+	conflicts := 0
+	writeLines := func(lines []string) {
+		for _, line := range lines {
+			out.WriteString(line)
+		}
+	}
+	writeMarker := func(marker string, label string) {
+		if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteString("\n")
+		}
+		out.WriteString(marker)
+		if label != "" {
+			out.WriteString(" " + label)
+		}
+		out.WriteString("\n")
+	}
+
+	lo, la, lb := 0, 0, 0
+	for lo < len(o) || la < len(a) || lb < len(b) {
+		// Stable chunk
+		i := 0
+		for lo+i < len(o) && matchA[lo+i] == la+i && matchB[lo+i] == lb+i {
+			i++
+		}
+		if i > 0 {
+			writeLines(o[lo : lo+i])
+			lo, la, lb = lo+i, la+i, lb+i
+			continue
+		}
+
+		// Unstable chunk up to the next line matched on both sides
+		next := lo
+		for next < len(o) && (matchA[next] < la || matchB[next] < lb) {
+			next++
+		}
+		endA, endB := len(a), len(b)
+		if next < len(o) {
+			endA, endB = matchA[next], matchB[next]
+		}
+		chunkO, chunkA, chunkB := o[lo:next], a[la:endA], b[lb:endB]
+
+		switch {
+		case equalLines(chunkA, chunkO):
+			writeLines(chunkB)
+		case equalLines(chunkB, chunkO), equalLines(chunkA, chunkB):
+			writeLines(chunkA)
+		default:
+			conflicts++
+			writeMarker("<<<<<<<", labels.ours)
+			writeLines(chunkA)
+			writeMarker("|||||||", labels.base)
+			writeLines(chunkO)
+			writeMarker("=======", "")
+			writeLines(chunkB)
+			writeMarker(">>>>>>>", labels.theirs)
+		}
+		lo, la, lb = next, endA, endB
+	}
+
+	return out.Bytes(), conflicts
+}
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffOp is an edit of a line diff, a and b are the line positions in both
+// sides.
+type diffOp struct {
+	kind byte
+	a    int
+	b    int
+}
+
+func diffOps(a []string, b []string) []diffOp {
+	matches := matchLines(a, b)
+	var ops []diffOp
+	j := 0
+	for i := range a {
+		if matches[i] < 0 {
+			ops = append(ops, diffOp{'-', i, j})
+			continue
+		}
+		for ; j < matches[i]; j++ {
+			ops = append(ops, diffOp{'+', i, j})
+		}
+		ops = append(ops, diffOp{' ', i, j})
+		j++
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', len(a), j})
+	}
+	return ops
+}
+
+// unifiedDiff returns the differences between a and b in unified format with
+// 3 lines of context, empty if contents are equal.
+func unifiedDiff(nameA string, nameB string, a []byte, b []byte) string {
+	const context = 3
+	linesA, linesB := splitLines(a), splitLines(b)
+	ops := diffOps(linesA, linesB)
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+
+		end := start
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*context {
+				end = next
+				continue
+			}
+			break
+		}
+
+		hunkStart, hunkEnd := start-context, end+context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		if hunkEnd > len(ops) {
+			hunkEnd = len(ops)
+		}
+
+		countA, countB := 0, 0
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		startA, startB := ops[hunkStart].a, ops[hunkStart].b
+		if countA > 0 {
+			startA++
+		}
+		if countB > 0 {
+			startB++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+
+		for _, op := range ops[hunkStart:hunkEnd] {
+			line := ""
+			if op.kind == '+' {
+				line = linesB[op.b]
+			} else {
+				line = linesA[op.a]
+			}
+			out.WriteByte(op.kind)
+			out.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = hunkEnd
+	}
+	return out.String()
+}
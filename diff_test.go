@@ -0,0 +1,141 @@
+package main
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	labels := mergeLabels{ours: "yours", base: "TGE v0.1.0", theirs: "TGE v0.2.0"}
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		merged    string
+		conflicts int
+	}{
+		{
+			name:   "unchanged",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nb\nc\n",
+			merged: "a\nb\nc\n",
+		},
+		{
+			name:   "changed by theirs",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nB\nc\n",
+			merged: "a\nB\nc\n",
+		},
+		{
+			name:   "changed by ours",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\nd\n",
+			theirs: "a\nb\nc\n",
+			merged: "a\nb\nc\nd\n",
+		},
+		{
+			name:   "distinct changes",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "A\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			merged: "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:   "same change",
+			base:   "a\nb\nc\n",
+			ours:   "a\nx\nc\n",
+			theirs: "a\nx\nc\n",
+			merged: "a\nx\nc\n",
+		},
+		{
+			name:      "overlapping changes",
+			base:      "a\nb\nc\n",
+			ours:      "a\nours\nc\n",
+			theirs:    "a\ntheirs\nc\n",
+			merged:    "a\n<<<<<<< yours\nours\n||||||| TGE v0.1.0\nb\n=======\ntheirs\n>>>>>>> TGE v0.2.0\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "missing final newline",
+			base:      "a\nb",
+			ours:      "a\nours",
+			theirs:    "a\ntheirs",
+			merged:    "a\n<<<<<<< yours\nours\n||||||| TGE v0.1.0\nb\n=======\ntheirs\n>>>>>>> TGE v0.2.0\n",
+			conflicts: 1,
+		},
+		{
+			name:      "two conflicts",
+			base:      "a\nb\nc\nd\ne\n",
+			ours:      "1\nb\nc\nd\n2\n",
+			theirs:    "3\nb\nc\nd\n4\n",
+			merged:    "<<<<<<< yours\n1\n||||||| TGE v0.1.0\na\n=======\n3\n>>>>>>> TGE v0.2.0\nb\nc\nd\n<<<<<<< yours\n2\n||||||| TGE v0.1.0\ne\n=======\n4\n>>>>>>> TGE v0.2.0\n",
+			conflicts: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, conflicts := merge3([]byte(test.base), []byte(test.ours), []byte(test.theirs), labels)
+			if string(merged) != test.merged {
+				t.Errorf("merge3() =\n%s\nwant\n%s", merged, test.merged)
+			}
+			if conflicts != test.conflicts {
+				t.Errorf("merge3() conflicts = %d, want %d", conflicts, test.conflicts)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		diff string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			diff: "",
+		},
+		{
+			name: "two hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n",
+			b:    "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\nsixteen\n",
+			diff: "--- a\n+++ b\n" +
+				"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+				"@@ -13,3 +13,4 @@\n 13\n 14\n 15\n+sixteen\n",
+		},
+		{
+			name: "merged hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\neight\n",
+			diff: "--- a\n+++ b\n@@ -1,8 +1,8 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n",
+		},
+		{
+			name: "missing final newline",
+			a:    "x\ny",
+			b:    "x\nz\n",
+			diff: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n x\n-y\n\\ No newline at end of file\n+z\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "new\n",
+			diff: "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+new\n",
+		},
+		{
+			name: "removed lines",
+			a:    "a\nb\n",
+			b:    "",
+			diff: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := unifiedDiff("a", "b", []byte(test.a), []byte(test.b)); diff != test.diff {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", diff, test.diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// errorKind is the category of a tge-cli error, its value is the exit code of
+// the command.
+type errorKind int
+
+// Error categories, values are stable exit codes
+const (
+	errFailure     errorKind = 1 // unexpected failure
+	errUsage       errorKind = 2 // invalid command line
+	errToolchain   errorKind = 3 // go or a required tool is missing
+	errProject     errorKind = 4 // invalid workspace, manifest, hook or TGE dependency
+	errCompile     errorKind = 5 // go build or gomobile build failed
+	errPackaging   errorKind = 6 // application packaging failed
+	errToolInstall errorKind = 7 // tool installation failed
+	errBudget      errorKind = 8 // size budget exceeded
+)
+
+// cliError is a categorized error wrapping its cause
+type cliError struct {
+	kind  errorKind
+	msg   string
+	cause error
+}
+
+func (e *cliError) Error() string {
+	if e.cause == nil {
+		return e.msg
+	}
+	return fmt.Sprintf("%s: %s", e.msg, e.cause)
+}
+
+func (e *cliError) Unwrap() error {
+	return e.cause
+}
+
+// newError returns an error of kind with message format, cause can be nil
+func newError(kind errorKind, cause error, format string, args ...interface{}) error {
+	return &cliError{kind, fmt.Sprintf(format, args...), cause}
+}
+
+// execError is the failure of a child process, the end of its standard error
+// output is kept in stderr.
+type execError struct {
+	name   string
+	err    error
+	stderr string
+}
+
+// Error returns the process failure followed by the last line of its stderr
+func (e *execError) Error() string {
+	if e.stderr == "" {
+		return fmt.Sprintf("%s: %s", e.name, e.err)
+	}
+	lines := strings.Split(e.stderr, "\n")
+	return fmt.Sprintf("%s: %s (%s)", e.name, e.err, strings.TrimSpace(lines[len(lines)-1]))
+}
+
+func (e *execError) Unwrap() error {
+	return e.err
+}
+
+// maxStderrSize is the size of the child stderr tail kept in execError
+const maxStderrSize = 4096
+
+// tailWriter keeps the last bytes written
+type tailWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	if extra := w.buf.Len() - maxStderrSize; extra > 0 {
+		w.buf.Next(extra)
+	}
+	return len(p), nil
+}
+
+// runCommand runs cmd with its output forwarded to the console, an execError
+// holding the end of stderr is returned on failure.
+func runCommand(cmd *exec.Cmd) error {
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	stderr := &tailWriter{}
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	if err := cmd.Run(); err != nil {
+		return &execError{filepath.Base(cmd.Path), err, strings.TrimSpace(stderr.buf.String())}
+	}
+	return nil
+}
+
+// exitCode returns the exit code of err, 1 if not categorized
+func exitCode(err error) int {
+	var e *cliError
+	if errors.As(err, &e) {
+		return int(e.kind)
+	}
+	return int(errFailure)
+}
+
+// fail logs err and exits with its exit code
+func fail(err error) {
+	log("ERROR", err.Error())
+	os.Exit(exitCode(err))
+}
+
+var exitCodesHelp = `Exit codes:
+    0   success
+    1   unexpected failure
+    2   invalid command line
+    3   toolchain missing (go or a required tool not installed)
+    4   invalid project (workspace, manifest, resources or TGE dependency)
+    5   compile failed
+    6   packaging failed
+    7   tool install failed
+    8   size budget exceeded`
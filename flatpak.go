@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FlatpakSettings holds the settings of Flatpak manifests
+type FlatpakSettings struct {
+	// Runtime is the runtime of the application, default is
+	// org.freedesktop.Platform
+	Runtime string `json:"runtime,omitempty"`
+	// RuntimeVersion is the version of the runtime and SDK, default is 23.08
+	RuntimeVersion string `json:"runtimeVersion,omitempty"`
+	// Sdk is the SDK used to build the application, default is
+	// org.freedesktop.Sdk
+	Sdk string `json:"sdk,omitempty"`
+	// FinishArgs are the sandbox permissions, default gives access to the
+	// display, GL, audio and input devices
+	FinishArgs []string `json:"finishArgs,omitempty"`
+}
+
+// defaultFlatpakFinishArgs give access to X11/Wayland, GL and input devices
+// (gamepads) and PulseAudio
+var defaultFlatpakFinishArgs = []string{
+	"--share=ipc",
+	"--socket=x11",
+	"--socket=wayland",
+	"--device=all",
+	"--socket=pulseaudio",
+}
+
+// flatpakManifest is a flatpak-builder manifest in JSON format
+type flatpakManifest struct {
+	AppID          string              `json:"app-id"`
+	Runtime        string              `json:"runtime"`
+	RuntimeVersion string              `json:"runtime-version"`
+	Sdk            string              `json:"sdk"`
+	SdkExtensions  []string            `json:"sdk-extensions"`
+	Command        string              `json:"command"`
+	FinishArgs     []string            `json:"finish-args"`
+	BuildOptions   flatpakBuildOptions `json:"build-options"`
+	Modules        []flatpakModule     `json:"modules"`
+}
+
+type flatpakBuildOptions struct {
+	AppendPath string            `json:"append-path"`
+	Env        map[string]string `json:"env"`
+	BuildArgs  []string          `json:"build-args,omitempty"`
+}
+
+type flatpakModule struct {
+	Name          string          `json:"name"`
+	Buildsystem   string          `json:"buildsystem"`
+	BuildCommands []string        `json:"build-commands"`
+	Sources       []flatpakSource `json:"sources"`
+}
+
+type flatpakSource struct {
+	Type string   `json:"type"`
+	Path string   `json:"path"`
+	Skip []string `json:"skip,omitempty"`
+}
+
+// writeFlatpak writes the Flatpak manifest of the Linux application in
+// dist/flatpak along with its desktop entry, launcher and icons, the package
+// is built by flatpak-builder.
+func (builder *Builder) writeFlatpak(outPath string, buildCommand string) error {
+	metadata := builder.packageMetadata()
+	var settings FlatpakSettings
+	if builder.manifest != nil {
+		settings = builder.manifest.Package.Flatpak
+	}
+	if settings.Runtime == "" {
+		settings.Runtime = "org.freedesktop.Platform"
+	}
+	if settings.RuntimeVersion == "" {
+		settings.RuntimeVersion = "23.08"
+	}
+	if settings.Sdk == "" {
+		settings.Sdk = "org.freedesktop.Sdk"
+	}
+	if len(settings.FinishArgs) == 0 {
+		settings.FinishArgs = defaultFlatpakFinishArgs
+	}
+	appID := builder.packageID()
+	launcher := metadata.name + "-launcher"
+
+	// Files installed from dist/flatpak, the desktop entry and icons must be
+	// named after the application ID
+	entryMetadata := metadata
+	entryMetadata.name = appID
+	files := map[string][]byte{
+		launcher:           []byte(fmt.Sprintf("#!/bin/sh\ncd /app/share/%s || exit 1\nexec /app/bin/%s \"$@\"\n", metadata.name, metadata.name)),
+		appID + ".desktop": []byte(entryMetadata.desktopEntry(launcher, "", builder.profile.console)),
+	}
+	commands := []string{
+		buildCommand,
+		fmt.Sprintf("install -Dm755 %s /app/bin/%s", metadata.name, metadata.name),
+		fmt.Sprintf("install -Dm755 %s /app/bin/%s", launcher, launcher),
+		fmt.Sprintf("mkdir -p /app/share/%s && cp -r %s /app/share/%s/", metadata.name, assetsPath, metadata.name),
+		fmt.Sprintf("install -Dm644 %s.desktop /app/share/applications/%s.desktop", appID, appID),
+	}
+	if iconPath := builder.packageIcon(); iconPath != "" {
+		for _, size := range hicolorIconSizes {
+			icon, err := resizedPNG(iconPath, size)
+			if err != nil {
+				return fmt.Errorf("failed to generate icon: %s", err)
+			}
+			name := fmt.Sprintf("icon-%d.png", size)
+			files[name] = icon
+			commands = append(commands, fmt.Sprintf("install -Dm644 %s /app/share/icons/hicolor/%dx%d/apps/%s.png", name, size, size, appID))
+		}
+	}
+
+	sources := []flatpakSource{builder.flatpakProjectSource(outPath)}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(outPath, name), content, 0644); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(files) {
+		sources = append(sources, flatpakSource{Type: "file", Path: name})
+	}
+
+	manifest := flatpakManifest{
+		AppID:          appID,
+		Runtime:        settings.Runtime,
+		RuntimeVersion: settings.RuntimeVersion,
+		Sdk:            settings.Sdk,
+		SdkExtensions:  []string{"org.freedesktop.Sdk.Extension.golang"},
+		Command:        launcher,
+		FinishArgs:     settings.FinishArgs,
+		BuildOptions: flatpakBuildOptions{
+			AppendPath: "/usr/lib/sdk/golang/bin",
+			Env:        map[string]string{"GOPATH": "/run/build/" + metadata.name + "/go", "CGO_ENABLED": "1"},
+		},
+		Modules: []flatpakModule{{
+			Name:          metadata.name,
+			Buildsystem:   "simple",
+			BuildCommands: commands,
+			Sources:       sources,
+		}},
+	}
+	// Go modules are downloaded during the build unless they are vendored
+	if _, err := os.Stat(filepath.Join(builder.packagePath, "vendor", "modules.txt")); err != nil {
+		manifest.BuildOptions.BuildArgs = []string{"--share=network"}
+		log("WARNING", "flatpak-builder needs network access to download Go modules, run 'go mod vendor' to build offline")
+	}
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(manifest)
+	manifestPath := filepath.Join(outPath, appID+".json")
+	if err := ioutil.WriteFile(manifestPath, content.Bytes(), 0644); err != nil {
+		return err
+	}
+	log("NOTICE", fmt.Sprintf("Flatpak manifest written: %s", manifestPath))
+	log("NOTICE", fmt.Sprintf("build it with: flatpak-builder --user --install build %s.json", appID))
+	return nil
+}
+
+// flatpakProjectSource returns the source of the project relative to outPath, dist
+// folder is skipped
+func (builder *Builder) flatpakProjectSource(outPath string) flatpakSource {
+	source := flatpakSource{Type: "dir", Path: relativePath(outPath, builder.packagePath)}
+	distPath := resolvePath(builder.packagePath, builder.config.get("dist"))
+	if rel, err := filepath.Rel(builder.packagePath, distPath); err == nil && !strings.HasPrefix(rel, "..") {
+		source.Skip = []string{filepath.ToSlash(rel)}
+	}
+	return source
+}
+
+// relativePath returns target relative to basePath in slash format, target
+// itself if not relative
+func relativePath(basePath string, target string) string {
+	rel, err := filepath.Rel(basePath, target)
+	if err != nil {
+		return filepath.ToSlash(target)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// sortedKeys returns the sorted keys of files
+func sortedKeys(files map[string][]byte) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,6 @@
 module github.com/thommil/tge-cli
 
-go 1.12
+go 1.16
 
 require (
 	github.com/hugocarreira/go-decent-copy v0.0.0-20181018112419-9f482c9a2943
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BuildFlags are go build flags added to the ones injected by tge-cli
+type BuildFlags struct {
+	Tags    []string `json:"tags,omitempty"`
+	Ldflags string   `json:"ldflags,omitempty"`
+	Gcflags string   `json:"gcflags,omitempty"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// goBuildFlags accumulates build flags from several sources, tags are merged
+// and ldflags/gcflags are concatenated so no source overwrites another.
+type goBuildFlags struct {
+	tags    []string
+	ldflags []string
+	gcflags []string
+	args    []string
+}
+
+func (flags *goBuildFlags) add(other BuildFlags) {
+	for _, tags := range other.Tags {
+		flags.addTags(tags)
+	}
+	if other.Ldflags != "" {
+		flags.ldflags = append(flags.ldflags, other.Ldflags)
+	}
+	if other.Gcflags != "" {
+		flags.gcflags = append(flags.gcflags, other.Gcflags)
+	}
+	flags.args = append(flags.args, other.Args...)
+}
+
+// addTags adds comma or space separated tags, duplicates are ignored
+func (flags *goBuildFlags) addTags(tags string) {
+	for _, tag := range strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ' ' }) {
+		found := false
+		for _, t := range flags.tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			flags.tags = append(flags.tags, tag)
+		}
+	}
+}
+
+// params returns the flags as go build (or gomobile build) parameters
+func (flags *goBuildFlags) params() []string {
+	var params []string
+	if len(flags.tags) > 0 {
+		params = append(params, fmt.Sprintf("-tags=%s", strings.Join(flags.tags, ",")))
+	}
+	if len(flags.ldflags) > 0 {
+		params = append(params, fmt.Sprintf("-ldflags=%s", strings.Join(flags.ldflags, " ")))
+	}
+	if len(flags.gcflags) > 0 {
+		params = append(params, fmt.Sprintf("-gcflags=%s", strings.Join(flags.gcflags, " ")))
+	}
+	return append(params, flags.args...)
+}
+
+// mergeBuildFlags merges the flags injected by tge-cli with the ones from the
+// manifest (global, desktop for desktop targets, then target) and command line.
+func (builder *Builder) mergeBuildFlags(injected goBuildFlags) goBuildFlags {
+	flags := injected
+	if builder.manifest != nil {
+		flags.add(builder.manifest.Build)
+		switch builder.target {
+		case "darwin", "windows", "linux":
+			flags.add(builder.manifest.Targets["desktop"].BuildFlags)
+		}
+		flags.add(builder.manifest.Targets[builder.target].BuildFlags)
+	}
+	flags.add(builder.buildFlags)
+	return flags
+}
+
+// goBuildParams returns the go build parameters of the merged flags, -v is
+// added in verbose mode.
+func (builder *Builder) goBuildParams(injected goBuildFlags) []string {
+	flags := builder.mergeBuildFlags(injected)
+	var params []string
+	if builder.verbose {
+		params = append(params, "-v")
+	}
+	return append(params, flags.params()...)
+}
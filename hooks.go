@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+const defaultHookTimeout = 10 * time.Minute
+
+// Build stages where hooks are run
+const (
+	preBuildHook    = "pre-build"
+	postCompileHook = "post-compile"
+	postPackageHook = "post-package"
+	postBuildHook   = "post-build"
+)
+
+var hookStages = []string{preBuildHook, postCompileHook, postPackageHook, postBuildHook}
+
+// Hook is a command run at a build stage, the command is run by the shell
+// (sh -c or cmd /C) from the workspace root.
+type Hook struct {
+	// Command is the shell command to run
+	Command string `json:"command"`
+	// Timeout is the maximum duration of the command (ex: 30s, 5m), default
+	// is 10m
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Hooks maps build stages to the hooks to run
+type Hooks map[string][]Hook
+
+// validate checks stages names and timeouts
+func (hooks Hooks) validate(origin string) error {
+	for stage, stageHooks := range hooks {
+		if indexOf(hookStages, stage) < 0 {
+			return newError(errProject, nil, "unknown hook stage '%s' in %s (available: %v)", stage, origin, hookStages)
+		}
+		for _, hook := range stageHooks {
+			if hook.Command == "" {
+				return newError(errProject, nil, "missing command for %s hook in %s", stage, origin)
+			}
+			if hook.Timeout != "" {
+				if _, err := time.ParseDuration(hook.Timeout); err != nil {
+					return newError(errProject, nil, "invalid timeout '%s' for %s hook in %s", hook.Timeout, stage, origin)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// runHooks runs the global hooks of stage then the ones of the current target
+// (desktop hooks are run for darwin, windows and linux), it stops at first
+// failure.
+func (builder *Builder) runHooks(stage string) error {
+	if builder.manifest == nil {
+		return nil
+	}
+	hooks := append([]Hook{}, builder.manifest.Hooks[stage]...)
+	switch builder.target {
+	case "darwin", "windows", "linux":
+		hooks = append(hooks, builder.manifest.Targets["desktop"].Hooks[stage]...)
+	}
+	hooks = append(hooks, builder.manifest.Targets[builder.target].Hooks[stage]...)
+
+	for _, hook := range hooks {
+		if err := builder.runHook(stage, hook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (builder *Builder) runHook(stage string, hook Hook) error {
+	timeout := defaultHookTimeout
+	if hook.Timeout != "" {
+		timeout, _ = time.ParseDuration(hook.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log("NOTICE", fmt.Sprintf("running %s hook: %s", stage, hook.Command))
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	}
+	cmd.Dir = builder.packagePath
+	cmd.Env = builder.environ(
+		fmt.Sprintf("TGE_BUILD_HOOK=%s", stage),
+		fmt.Sprintf("TGE_BUILD_TARGET=%s", builder.target),
+		fmt.Sprintf("TGE_BUILD_PROFILE=%s", builder.profile.name),
+		fmt.Sprintf("TGE_BUILD_DIST=%s", builder.distPath),
+		fmt.Sprintf("TGE_BUILD_PROGRAM=%s", builder.programName),
+		fmt.Sprintf("TGE_BUILD_PACKAGE_NAME=%s", builder.packageName),
+		fmt.Sprintf("TGE_BUILD_PACKAGE_PATH=%s", builder.packagePath),
+		fmt.Sprintf("TGE_BUILD_TGE_VERSION=%s", builder.tgeProjectVersion),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return newError(errProject, nil, "%s hook '%s' timed out after %s", stage, hook.Command, timeout)
+		}
+		return newError(errProject, err, "%s hook '%s' failed", stage, hook.Command)
+	}
+	return nil
+}
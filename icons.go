@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+)
+
+// resizePNG writes the PNG image at srcPath scaled to a size x size square
+func resizePNG(srcPath string, dstPath string, size int) error {
+	content, err := resizedPNG(srcPath, size)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dstPath, content, 0644)
+}
+
+// resizedPNG returns the PNG image at srcPath scaled to a size x size square
+func resizedPNG(srcPath string, size int) ([]byte, error) {
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/size
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/size
+		for x := 0; x < size; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/size
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/size
+			dst.Set(x, y, averageColor(src, x0, y0, x1, y1))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// averageColor returns the mean color of the [x0,x1[ x [y0,y1[ area, at least
+// one pixel is sampled
+func averageColor(img image.Image, x0, y0, x1, y1 int) color.NRGBA64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	var r, g, b, a, n uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			r += uint64(c.R)
+			g += uint64(c.G)
+			b += uint64(c.B)
+			a += uint64(c.A)
+			n++
+		}
+	}
+	return color.NRGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)}
+}
@@ -3,77 +3,335 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
-
-	"github.com/otiai10/copy"
+	"syscall"
 )
 
 func (builder *Builder) initWorkspace(packageArg string) error {
 	builder.packageName = packageArg
-	if index := strings.LastIndex(builder.packageName, "/"); index >= 0 {
-		builder.packagePath = filepath.Join(builder.cwd, builder.packageName[index:])
+	var workspacePath string
+	if builder.here {
+		workspacePath = builder.cwd
+	} else if index := strings.LastIndex(builder.packageName, "/"); index >= 0 {
+		workspacePath = filepath.Join(builder.cwd, builder.packageName[index:])
 	} else {
-		builder.packagePath = filepath.Join(builder.cwd, builder.packageName)
+		workspacePath = filepath.Join(builder.cwd, builder.packageName)
+	}
+
+	_, err := os.Stat(workspacePath)
+	workspaceExists := !os.IsNotExist(err)
+	if workspaceExists && !builder.here && !builder.merge {
+		return newError(errUsage, nil, "path %s already exists (use -merge to scaffold into it)", workspacePath)
+	}
+
+	if modulePath := readModulePath(filepath.Join(workspacePath, "go.mod")); modulePath != "" {
+		if builder.packageName != "" && builder.packageName != modulePath {
+			log("WARNING", fmt.Sprintf("using existing module path '%s' instead of '%s'", modulePath, builder.packageName))
+		}
+		builder.packageName = modulePath
+	} else if builder.packageName == "" {
+		builder.packageName = filepath.Base(workspacePath)
+	}
+
+	if err := builder.loadConfig(workspacePath); err != nil {
+		return err
 	}
 
-	if _, err := os.Stat(builder.packagePath); os.IsNotExist(err) {
-		if err = os.MkdirAll(builder.packagePath, os.ModeDir|os.FileMode(0777)); err != nil {
+	// Staging
+	defer builder.cleanOnSignal()()
+	if err := initStep("prepare staging directory", func() error {
+		stagingPath, err := stagingDir(workspacePath, workspaceExists)
+		if err != nil {
 			return err
 		}
-	} else {
-		log("ERROR", fmt.Sprintf("path %s already exists", builder.packagePath))
-		os.Exit(2)
+		if err = os.Chmod(stagingPath, os.ModeDir|0755); err != nil {
+			return err
+		}
+		builder.stagingPath = stagingPath
+		builder.packagePath = stagingPath
+
+		if workspaceExists {
+			for _, p := range []string{"go.mod", "go.sum"} {
+				if info, err := os.Stat(filepath.Join(workspacePath, p)); err == nil {
+					if err = copyFile(filepath.Join(workspacePath, p), filepath.Join(stagingPath, p), info.Mode()); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return os.Chdir(stagingPath)
+	}); err != nil {
+		return err
+	}
+
+	// TGE
+	if err := initStep("install TGE", builder.installTGE); err != nil {
+		return err
 	}
 
-	if err := os.Chdir(builder.packagePath); err != nil {
+	// Project files
+	if err := initStep("render project files", func() error {
+		log("NOTICE", "Initializing project files")
+		if _, err := renderTemplateDir(filepath.Join(builder.tgeRootPath, tgeTemplatePath), builder.packagePath, builder.templateVars(), false); err != nil {
+			return newError(errProject, err, "failed to render project files, try manually from '%s'", filepath.Join(builder.tgeRootPath, tgeTemplatePath))
+		}
+		for _, target := range tgeTargets {
+			if _, err := os.Stat(filepath.Join(builder.tgeRootPath, tgeTemplatePath, target)); err == nil {
+				if err = builder.saveResourcesBase(target); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	if err := builder.installTGE(); err != nil {
+	// Workspace
+	var report *renderReport
+	if err := initStep("move workspace into place", func() error {
+		if err := os.Chdir(builder.cwd); err != nil {
+			return err
+		}
+		// Moves are not interrupted, they are rolled back on failure
+		signal.Ignore(os.Interrupt, syscall.SIGTERM)
+		defer signal.Reset(os.Interrupt, syscall.SIGTERM)
+		if workspaceExists {
+			if report, err = mergeDir(builder.stagingPath, workspacePath, builder.force); err != nil {
+				return err
+			}
+			removeAll(builder.stagingPath)
+		} else if err := os.Rename(builder.stagingPath, workspacePath); err != nil {
+			return err
+		}
+		builder.stagingPath = ""
+		builder.packagePath = workspacePath
+		return os.Chdir(workspacePath)
+	}); err != nil {
 		return err
 	}
 
-	log("NOTICE", "Initializing project files")
-	if err := copy.Copy(filepath.Join(builder.tgeRootPath, tgeTemplatePath), builder.packagePath); err != nil {
-		log("ERROR", err.Error())
-		return fmt.Errorf("Failed to copy project files, try manually from '%s", filepath.Join(builder.tgeRootPath, tgeTemplatePath))
+	if report != nil {
+		for _, p := range report.overwritten {
+			log("NOTICE", fmt.Sprintf("overwritten %s", p))
+		}
+		if report.backupPath != "" {
+			log("NOTICE", fmt.Sprintf("previous versions of overwritten files are saved in %s", report.backupPath))
+		}
+		if len(report.conflicts) > 0 {
+			log("WARNING", fmt.Sprintf("%d existing files skipped (use -force to overwrite):", len(report.conflicts)))
+			for _, p := range report.conflicts {
+				fmt.Printf("    %s\n", p)
+			}
+		}
 	}
 
 	return nil
 }
 
-func (builder *Builder) cleanInitBuilder() {
-	os.RemoveAll(builder.packagePath)
+// initStep runs a step of the init process, failures are reported with the
+// step name.
+func initStep(name string, step func() error) error {
+	if err := step(); err != nil {
+		return fmt.Errorf("init failed at step '%s': %w", name, err)
+	}
+	return nil
 }
 
-func doInit(builder Builder) {
-	os.Args = os.Args[1:]
-	flag.Usage = func() { fmt.Println(initUsage) }
-	flag.Parse()
+// stagingDir creates the staging directory of the workspace in its .tge folder
+// if it exists (ex: -here), next to it otherwise, so that the workspace is moved
+// into place by renaming files.
+func stagingDir(workspacePath string, workspaceExists bool) (string, error) {
+	prefix := fmt.Sprintf(".%s.tge-init-", filepath.Base(workspacePath))
+	parentPath := filepath.Dir(workspacePath)
+	if workspaceExists {
+		parentPath = filepath.Join(workspacePath, tgeLocalPath)
+	}
+	if err := os.MkdirAll(parentPath, os.ModeDir|0755); err != nil {
+		return "", err
+	}
+	return ioutil.TempDir(parentPath, prefix)
+}
 
-	if len(flag.Args()) == 0 {
-		fmt.Println(initUsage)
-		return
+// mergeDir moves the content of srcPath into the existing dstPath, go.mod and
+// go.sum are always updated, other existing files are kept unless force is set.
+// Overwritten files are saved in a backup folder of dstPath/.tge, all moves are
+// reverted if one fails.
+func mergeDir(srcPath string, dstPath string, force bool) (*renderReport, error) {
+	report := &renderReport{}
+	var moved [][2]string
+	move := func(from string, to string) error {
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		moved = append(moved, [2]string{from, to})
+		return nil
 	}
+	backup := func(relPath string) error {
+		if report.backupPath == "" {
+			localPath := filepath.Join(dstPath, tgeLocalPath)
+			if err := os.MkdirAll(localPath, os.ModeDir|0755); err != nil {
+				return err
+			}
+			backupPath, err := ioutil.TempDir(localPath, "init-backup-")
+			if err != nil {
+				return err
+			}
+			report.backupPath = backupPath
+		}
+		backupFile := filepath.Join(report.backupPath, relPath)
+		if err := os.MkdirAll(filepath.Dir(backupFile), os.ModeDir|0755); err != nil {
+			return err
+		}
+		return move(filepath.Join(dstPath, relPath), backupFile)
+	}
+	err := filepath.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcPath, p)
+		if err != nil || relPath == "." {
+			return err
+		}
+		target := filepath.Join(dstPath, relPath)
+
+		existingInfo, statErr := os.Stat(target)
+		if os.IsNotExist(statErr) {
+			report.created = append(report.created, relPath)
+			if err = move(p, target); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() && existingInfo.IsDir() {
+			return nil
+		}
+		if !info.IsDir() && !existingInfo.IsDir() && (force || relPath == "go.mod" || relPath == "go.sum") {
+			report.overwritten = append(report.overwritten, relPath)
+			if err = backup(relPath); err != nil {
+				return err
+			}
+			return move(p, target)
+		}
+		report.conflicts = append(report.conflicts, relPath)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		for i := len(moved) - 1; i >= 0; i-- {
+			if rollbackErr := os.Rename(moved[i][1], moved[i][0]); rollbackErr != nil {
+				log("WARNING", fmt.Sprintf("failed to restore %s: %s", moved[i][0], rollbackErr))
+			}
+		}
+		if report.backupPath != "" {
+			removeAll(report.backupPath)
+		}
+	}
+	return report, err
+}
 
-	if err := builder.initWorkspace(flag.Args()[0]); err != nil {
-		log("ERROR", err.Error())
+// cleanOnSignal removes the staging directory if init is interrupted, the
+// returned function stops watching signals.
+func (builder *Builder) cleanOnSignal() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		log("ERROR", fmt.Sprintf("init interrupted (%s)", sig))
+		os.Chdir(builder.cwd)
 		builder.cleanInitBuilder()
 		os.Exit(1)
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(signals)
 	}
-
-	log("SUCCESS", "You can know build & deploy application using 'tge-cli build' command (see help)")
 }
 
-var initUsage = `tge-cli init creates a TGE workspace.
-	
-Usage:
-    tge-cli init package
+// cleanInitBuilder removes the staging directory, the workspace and user files
+// are never touched.
+func (builder *Builder) cleanInitBuilder() {
+	if builder.stagingPath != "" {
+		os.Chdir(builder.cwd)
+		removeAll(builder.stagingPath)
+	}
+}
 
-Package argument can be of several forms:
+var initCommand = &command{
+	name:    "init",
+	summary: "Create a new TGE project",
+	synopsis: []string{
+		"[flags] package",
+		"-here [-force] [flags] [package]",
+		"-merge [-force] [flags] package",
+	},
+	help: `Package argument can be of several forms:
     local   ex: my-app
     url     ex: github.com/me/my-app
-	
-In both cases, the last token will be used as worspace root.`
+
+In both cases, the last token will be used as worspace root.
+
+Project files are rendered from TGE template, files with the .tmpl suffix and
+file names containing {{ }} markers are processed using text/template with:
+    {{.PackageName}}    package argument (module path)
+    {{.AppName}}        application name
+    {{.BundleID}}       bundle ID / Android package
+    {{.Author}}         application author
+    {{.Year}}           current year
+    {{.TGEVersion}}     TGE version`,
+	notes: `The workspace is built in a staging directory next to the destination (or in
+its .tge folder if it already exists) and moved into place
+only when all steps succeed, the staging directory is removed on failure or
+interruption and files already moved are put back. With -here and -merge, an existing go.mod module path
+is reused and user files are never deleted.`,
+	completions: map[string][]string{"tge-path": dirsCompletion},
+	flags: func(fs *flag.FlagSet) {
+		fs.String("name", "", "application `name`, default to the last token of package")
+		fs.String("bundleid", "", "bundle `id` used for IOS and Android package, default is derived\nfrom package (ex: github.com/me/my-app -> com.github.me.myapp)")
+		fs.String("author", "", "application `author`, default from 'git config user.name'")
+		fs.Bool("here", false, "scaffold into the current directory (ex: after 'git clone'), the\npackage argument is optional and defaults to the folder name")
+		fs.Bool("merge", false, "scaffold into an existing workspace directory")
+		fs.Bool("force", false, "overwrite existing files instead of skipping, with -here or -merge,\nprevious versions are saved in .tge/init-backup-*")
+		offlineFlag(fs)
+		tgePathFlag(fs)
+		fs.String("tge-version", "", "TGE `version` to use (tag, commit or pseudo-version), recorded in the\ngo.mod requirement of the project. Default is the latest version.")
+	},
+	run: doInit,
+}
+
+func doInit(builder Builder, fs *flag.FlagSet, args []string) {
+	packageArg := ""
+	if len(args) > 0 {
+		packageArg = args[0]
+	} else if !flagBool(fs, "here") {
+		printCommandHelp("init")
+		return
+	}
+
+	builder.here = flagBool(fs, "here")
+	builder.merge = flagBool(fs, "merge")
+	builder.force = flagBool(fs, "force")
+	builder.flags = flagSettings(fs, "offline", "tge-path", "tge-version")
+	builder.appName = flagString(fs, "name")
+	builder.bundleID = flagString(fs, "bundleid")
+	builder.author = flagString(fs, "author")
+	if err := builder.initWorkspace(packageArg); err != nil {
+		builder.cleanInitBuilder()
+		fail(err)
+	}
+
+	log("SUCCESS", "You can know build & deploy application using 'tge-cli build' command (see help)")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const defaultMacOSMinimumVersion = "10.13"
+
+// MacOSSettings holds the settings of MacOS application bundles
+type MacOSSettings struct {
+	// MinimumVersion is the minimum MacOS version (LSMinimumSystemVersion),
+	// default is 10.13
+	MinimumVersion string `json:"minimumVersion,omitempty"`
+	// HighDPI enables Retina resolution (NSHighResolutionCapable), default is
+	// true
+	HighDPI *bool `json:"highDPI,omitempty"`
+	// Category is the App Store category (LSApplicationCategoryType), default
+	// is public.app-category.games
+	Category string `json:"category,omitempty"`
+}
+
+// icnsTypes maps the sizes of PNG icons to their ICNS types
+var icnsTypes = []struct {
+	size     int
+	iconType string
+}{
+	{16, "icp4"},
+	{32, "icp5"},
+	{64, "icp6"},
+	{128, "ic07"},
+	{256, "ic08"},
+	{512, "ic09"},
+	{1024, "ic10"},
+}
+
+// plistEntry is a key of Info.plist, value is a string or a bool
+type plistEntry struct {
+	key   string
+	value interface{}
+}
+
+// writePlist writes entries as an XML property list dictionary
+func writePlist(path string, entries []plistEntry) error {
+	var plist bytes.Buffer
+	plist.WriteString(xml.Header)
+	plist.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	plist.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	for _, entry := range entries {
+		plist.WriteString("\t<key>")
+		xml.EscapeText(&plist, []byte(entry.key))
+		plist.WriteString("</key>\n")
+		switch value := entry.value.(type) {
+		case bool:
+			fmt.Fprintf(&plist, "\t<%t/>\n", value)
+		default:
+			plist.WriteString("\t<string>")
+			xml.EscapeText(&plist, []byte(fmt.Sprint(value)))
+			plist.WriteString("</string>\n")
+		}
+	}
+	plist.WriteString("</dict>\n</plist>\n")
+	return ioutil.WriteFile(path, plist.Bytes(), 0644)
+}
+
+// writeICNS writes an ICNS icon embedding PNG icons resized from iconPath
+func writeICNS(iconPath string, icnsPath string) error {
+	var entries bytes.Buffer
+	for _, icns := range icnsTypes {
+		icon, err := resizedPNG(iconPath, icns.size)
+		if err != nil {
+			return err
+		}
+		entries.WriteString(icns.iconType)
+		binary.Write(&entries, binary.BigEndian, uint32(8+len(icon)))
+		entries.Write(icon)
+	}
+	var content bytes.Buffer
+	content.WriteString("icns")
+	binary.Write(&content, binary.BigEndian, uint32(8+entries.Len()))
+	content.Write(entries.Bytes())
+	return ioutil.WriteFile(icnsPath, content.Bytes(), 0644)
+}
+
+// packageMacOS moves the binary in dist to a MacOS application bundle with its
+// Info.plist and icon, assets are copied in Contents/Resources by the caller.
+// No MacOS tool is used so bundles can be built from any OS.
+func (builder *Builder) packageMacOS(binaryFile string) (string, error) {
+	metadata := builder.packageMetadata()
+	var settings MacOSSettings
+	if builder.manifest != nil {
+		settings = builder.manifest.Package.MacOS
+	}
+	if settings.MinimumVersion == "" {
+		settings.MinimumVersion = defaultMacOSMinimumVersion
+	}
+	if settings.Category == "" {
+		settings.Category = "public.app-category.games"
+	}
+	highDPI := settings.HighDPI == nil || *settings.HighDPI
+
+	bundlePath := filepath.Join(builder.distPath, fmt.Sprintf("%s.app", builder.programName))
+	log("NOTICE", fmt.Sprintf("Packaging %s", bundlePath))
+	if err := os.RemoveAll(bundlePath); err != nil {
+		return "", err
+	}
+	macOSPath := filepath.Join(bundlePath, "Contents", "MacOS")
+	resourcesPath := filepath.Join(bundlePath, "Contents", "Resources")
+	for _, dir := range []string{macOSPath, resourcesPath} {
+		if err := os.MkdirAll(dir, os.ModeDir|0755); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Rename(filepath.Join(builder.distPath, binaryFile), filepath.Join(macOSPath, builder.programName)); err != nil {
+		return "", err
+	}
+
+	entries := []plistEntry{
+		{"CFBundleDevelopmentRegion", "en"},
+		{"CFBundleExecutable", builder.programName},
+		{"CFBundleIdentifier", builder.packageID()},
+		{"CFBundleInfoDictionaryVersion", "6.0"},
+		{"CFBundleName", builder.programName},
+		{"CFBundleDisplayName", metadata.title},
+		{"CFBundlePackageType", "APPL"},
+		{"CFBundleShortVersionString", metadata.version},
+		{"CFBundleVersion", metadata.version},
+		{"LSApplicationCategoryType", settings.Category},
+		{"LSMinimumSystemVersion", settings.MinimumVersion},
+		{"NSHighResolutionCapable", highDPI},
+	}
+
+	// Icon, icon.icns of the darwin folder or generated from icon.png
+	icnsPath := filepath.Join(builder.packagePath, builder.target, "icon.icns")
+	if content, found := readOptionalFile(icnsPath); found {
+		if err := ioutil.WriteFile(filepath.Join(resourcesPath, "icon.icns"), content, 0644); err != nil {
+			return "", err
+		}
+		entries = append(entries, plistEntry{"CFBundleIconFile", "icon.icns"})
+	} else if iconPath := builder.packageIcon(); iconPath != "" {
+		if err := writeICNS(iconPath, filepath.Join(resourcesPath, "icon.icns")); err != nil {
+			return "", fmt.Errorf("failed to generate icon.icns: %s", err)
+		}
+		entries = append(entries, plistEntry{"CFBundleIconFile", "icon.icns"})
+	}
+	if metadata.maintainer != "" {
+		entries = append(entries, plistEntry{"NSHumanReadableCopyright", fmt.Sprintf("Copyright %d %s", builder.templateVars().Year, metadata.maintainer)})
+	}
+
+	if err := writePlist(filepath.Join(bundlePath, "Contents", "Info.plist"), entries); err != nil {
+		return "", err
+	}
+	return resourcesPath, ioutil.WriteFile(filepath.Join(bundlePath, "Contents", "PkgInfo"), []byte("APPL????"), 0644)
+}
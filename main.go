@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"runtime"
 )
@@ -12,33 +11,26 @@ func init() {
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println(usage)
+		builder := Builder{}
+		builder.cwd, _ = os.Getwd()
+		printUsage(builder)
 		return
 	}
 
-	switch os.Args[1] {
-	case "init":
-		doInit(createBuilder())
-	case "build":
-		doBuild(createBuilder())
-	case "version":
-		fmt.Printf("TGE %s\n", tgeVersion)
-	default:
-		fmt.Println(usage)
+	builder := createBuilder()
+	if c := lookupCommand(os.Args[1]); c != nil {
+		c.execute(builder, os.Args[2:])
+		return
 	}
-}
 
-var usage = `TGE command line tool creates, builds and packages TGE applications.
-
-To install:
-    $ go get github.com/thommil/tge-cli
-	
-Usage:
-    tge-cli [command] [options] arguments
-	
-Available commands:
-    init      Create a new TGE project
-    build     Build & package TGE applications
-    version   Print TGE version
+	switch os.Args[1] {
+	case "-h", "-help", "--help":
+		printUsage(builder)
+		return
+	}
 
-Use 'tge-cli command -h ' for get help on commands.`
+	if p := lookupPlugin(findProject(builder.cwd), os.Args[1]); p != nil {
+		builder.runPlugin(p, os.Args[2:])
+	}
+	fail(newError(errUsage, nil, "unknown command '%s' (see 'tge-cli help')", os.Args[1]))
+}
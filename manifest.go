@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const manifestFile = "tge.json"
+
+// Manifest holds the project settings stored in tge.json at workspace root,
+// the same format is used for the user configuration file. Relative paths are
+// resolved from the file location.
+type Manifest struct {
+	// Offline disables all network access, TGE is resolved from tgePath, the
+	// vendor directory or the modules cache
+	Offline *bool `json:"offline,omitempty"`
+	// TGEPath points to a local TGE checkout
+	TGEPath string `json:"tgePath,omitempty"`
+	// TGEVersion is the TGE version to use (tag, commit or pseudo-version)
+	TGEVersion string `json:"tgeVersion,omitempty"`
+	// Verbose enables verbose output
+	Verbose *bool `json:"verbose,omitempty"`
+	// Target is the default build target
+	Target string `json:"target,omitempty"`
+	// Profile is the default build profile
+	Profile string `json:"profile,omitempty"`
+	// Compiler is the compiler of browser target (go or tinygo)
+	Compiler string `json:"compiler,omitempty"`
+	// Dist is the folder where applications are generated
+	Dist string `json:"dist,omitempty"`
+	// GoPath overrides GOPATH of go commands
+	GoPath string `json:"gopath,omitempty"`
+	// Tools sets the binary paths of external tools (gomobile, tinygo,
+	// appimagetool)
+	Tools map[string]string `json:"tools,omitempty"`
+	// Build adds go build flags to all targets
+	Build BuildFlags `json:"build,omitempty"`
+	// Targets adds go build flags and hooks by target (desktop, darwin,
+	// windows, linux, browser, android, ios)
+	Targets map[string]TargetSettings `json:"targets,omitempty"`
+	// Hooks are commands run at build stages for all targets
+	Hooks Hooks `json:"hooks,omitempty"`
+	// Browser sets the metadata of the generated browser page
+	Browser BrowserSettings `json:"browser,omitempty"`
+	// Package sets the metadata of the installable packages
+	Package PackageSettings `json:"package,omitempty"`
+	// Profiles defines build profiles, debug and release override the
+	// built-in ones
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// TargetSettings holds the manifest settings of a build target
+type TargetSettings struct {
+	BuildFlags
+	// Hooks are commands run at build stages of the target
+	Hooks Hooks `json:"hooks,omitempty"`
+	// Budget limits the size of the files generated for the target
+	Budget *SizeBudget `json:"budget,omitempty"`
+}
+
+// loadManifest reads the manifest of the workspace at packagePath, an empty
+// manifest is returned if the file does not exist.
+func loadManifest(packagePath string) (*Manifest, error) {
+	return loadManifestFile(filepath.Join(packagePath, manifestFile))
+}
+
+func loadManifestFile(path string) (*Manifest, error) {
+	manifest := &Manifest{}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, newError(errProject, err, "failed to read %s", path)
+	}
+	if err = json.Unmarshal(content, manifest); err != nil {
+		return nil, newError(errProject, err, "invalid %s", path)
+	}
+	if err = manifest.Hooks.validate(path); err != nil {
+		return nil, err
+	}
+	for _, target := range manifest.Targets {
+		if err = target.Hooks.validate(path); err != nil {
+			return nil, err
+		}
+		if err = target.Budget.validate(path); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// settings returns the configuration values defined in the manifest, relative
+// paths are resolved from basePath.
+func (manifest *Manifest) settings(basePath string) map[string]string {
+	values := map[string]string{}
+	if manifest.Offline != nil {
+		values["offline"] = fmt.Sprint(*manifest.Offline)
+	}
+	if manifest.TGEPath != "" {
+		values["tge-path"] = resolvePath(basePath, manifest.TGEPath)
+	}
+	if manifest.TGEVersion != "" {
+		values["tge-version"] = manifest.TGEVersion
+	}
+	if manifest.Verbose != nil {
+		values["verbose"] = fmt.Sprint(*manifest.Verbose)
+	}
+	if manifest.Target != "" {
+		values["target"] = manifest.Target
+	}
+	if manifest.Profile != "" {
+		values["profile"] = manifest.Profile
+	}
+	if manifest.Compiler != "" {
+		values["compiler"] = manifest.Compiler
+	}
+	if manifest.Dist != "" {
+		values["dist"] = resolvePath(basePath, manifest.Dist)
+	}
+	if manifest.GoPath != "" {
+		values["gopath"] = manifest.GoPath
+	}
+	for tool, path := range manifest.Tools {
+		if strings.ContainsAny(path, `/\`) {
+			path = resolvePath(basePath, path)
+		}
+		values["tools."+tool] = path
+	}
+	return values
+}
+
+func resolvePath(basePath string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(basePath, path)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const defaultPackageVersion = "1.0.0"
+
+// manifestFormats are the package formats generated by the package command
+var manifestFormats = []string{"flatpak", "snap"}
+
+// linuxPackageFormats are the installable package formats of Linux target
+var linuxPackageFormats = []string{"deb", "appimage"}
+
+// hicolorIconSizes are the sizes of the icons installed in the hicolor theme
+var hicolorIconSizes = []int{48, 64, 128, 256}
+
+// PackageSettings holds the metadata of the installable packages
+type PackageSettings struct {
+	// ID is the reverse domain application ID (Flatpak), default is derived
+	// from the module path
+	ID string `json:"id,omitempty"`
+	// Name is the package name, default is the program name
+	Name string `json:"name,omitempty"`
+	// Version is the package version, default is 1.0.0
+	Version string `json:"version,omitempty"`
+	// Title is the application name displayed in menus, default is the
+	// application name
+	Title string `json:"title,omitempty"`
+	// Maintainer is the package maintainer (Name <email>), default is the
+	// current user
+	Maintainer string `json:"maintainer,omitempty"`
+	// Description is the package description, the first line is the summary
+	Description string `json:"description,omitempty"`
+	// Homepage is the URL of the project
+	Homepage string `json:"homepage,omitempty"`
+	// Categories are the menu categories of the desktop entry, default is Game
+	Categories []string `json:"categories,omitempty"`
+	// Deb holds the settings specific to Debian packages
+	Deb DebSettings `json:"deb,omitempty"`
+	// Flatpak holds the settings specific to Flatpak manifests
+	Flatpak FlatpakSettings `json:"flatpak,omitempty"`
+	// Snap holds the settings specific to snapcraft.yaml
+	Snap SnapSettings `json:"snap,omitempty"`
+	// MacOS holds the settings specific to MacOS application bundles
+	MacOS MacOSSettings `json:"macos,omitempty"`
+}
+
+// packageMetadata is the resolved package metadata
+type packageMetadata struct {
+	name        string
+	version     string
+	title       string
+	maintainer  string
+	summary     string
+	description string
+	homepage    string
+	categories  []string
+}
+
+var desktopKeyPattern = regexp.MustCompile(`^[A-Za-z0-9-]+(\[[^\]]+\])?$`)
+
+// desktopBooleanKeys and desktopListKeys are the keys of desktop entries with
+// boolean and semicolon separated values
+var desktopBooleanKeys = []string{"Terminal", "NoDisplay", "Hidden", "StartupNotify", "DBusActivatable"}
+var desktopListKeys = []string{"Categories", "Keywords", "MimeType", "OnlyShowIn", "NotShowIn", "Actions", "Implements"}
+
+var packageNamePattern = regexp.MustCompile(`[^a-z0-9+.-]+`)
+
+// packageMetadata returns the package metadata from the manifest, missing
+// values are taken from the project.
+func (builder *Builder) packageMetadata() packageMetadata {
+	var settings PackageSettings
+	if builder.manifest != nil {
+		settings = builder.manifest.Package
+	}
+	vars := builder.templateVars()
+	metadata := packageMetadata{
+		name:        settings.Name,
+		version:     settings.Version,
+		title:       settings.Title,
+		maintainer:  settings.Maintainer,
+		description: strings.TrimSpace(settings.Description),
+		homepage:    settings.Homepage,
+		categories:  settings.Categories,
+	}
+	if metadata.name == "" {
+		metadata.name = strings.Trim(packageNamePattern.ReplaceAllString(strings.ToLower(builder.programName), "-"), "-")
+	}
+	if metadata.version == "" {
+		metadata.version = defaultPackageVersion
+	}
+	if metadata.title == "" {
+		metadata.title = vars.AppName
+	}
+	if metadata.maintainer == "" {
+		metadata.maintainer = vars.Author
+	}
+	if metadata.description == "" {
+		metadata.description = fmt.Sprintf("%s, a game made with TGE", metadata.title)
+	}
+	if len(metadata.categories) == 0 {
+		metadata.categories = []string{"Game"}
+	}
+	lines := strings.SplitN(metadata.description, "\n", 2)
+	metadata.summary = strings.TrimSpace(lines[0])
+	if len(lines) > 1 {
+		metadata.description = strings.TrimSpace(lines[1])
+	} else {
+		metadata.description = ""
+	}
+	return metadata
+}
+
+// packageID returns the reverse domain application ID
+func (builder *Builder) packageID() string {
+	if builder.manifest != nil && builder.manifest.Package.ID != "" {
+		return builder.manifest.Package.ID
+	}
+	return builder.templateVars().BundleID
+}
+
+// desktopEntry returns the freedesktop entry launching the program at
+// execPath from workDir
+func (metadata packageMetadata) desktopEntry(execPath string, workDir string, terminal bool) string {
+	var entry strings.Builder
+	entry.WriteString("[Desktop Entry]\n")
+	entry.WriteString("Type=Application\n")
+	fmt.Fprintf(&entry, "Name=%s\n", metadata.title)
+	fmt.Fprintf(&entry, "Comment=%s\n", metadata.summary)
+	fmt.Fprintf(&entry, "Exec=%s\n", execPath)
+	fmt.Fprintf(&entry, "Icon=%s\n", metadata.name)
+	if workDir != "" {
+		fmt.Fprintf(&entry, "Path=%s\n", workDir)
+	}
+	fmt.Fprintf(&entry, "Terminal=%t\n", terminal)
+	fmt.Fprintf(&entry, "Categories=%s;\n", strings.Join(metadata.categories, ";"))
+	return entry.String()
+}
+
+// validateDesktopEntry checks the format of a desktop entry against the
+// freedesktop specification, Icon and Categories are also required by AppImage.
+func validateDesktopEntry(entry string) error {
+	group := ""
+	keys := map[string]string{}
+	for i, line := range strings.Split(entry, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("line %d: invalid group header '%s'", i+1, line)
+			}
+			if group == "" && line != "[Desktop Entry]" {
+				return fmt.Errorf("line %d: first group must be [Desktop Entry]", i+1)
+			}
+			group = line
+			continue
+		case group == "":
+			return fmt.Errorf("line %d: entry outside of a group", i+1)
+		}
+		if group != "[Desktop Entry]" {
+			continue
+		}
+		index := strings.Index(line, "=")
+		if index < 0 {
+			return fmt.Errorf("line %d: invalid line '%s', expected key=value", i+1, line)
+		}
+		key, value := strings.TrimSpace(line[:index]), strings.TrimSpace(line[index+1:])
+		if !desktopKeyPattern.MatchString(key) {
+			return fmt.Errorf("line %d: invalid key '%s'", i+1, key)
+		}
+		if _, found := keys[key]; found {
+			return fmt.Errorf("line %d: duplicate key '%s'", i+1, key)
+		}
+		keys[key] = value
+		if indexOf(desktopBooleanKeys, key) >= 0 && value != "true" && value != "false" {
+			return fmt.Errorf("line %d: %s must be true or false", i+1, key)
+		}
+		if indexOf(desktopListKeys, key) >= 0 && value != "" && !strings.HasSuffix(value, ";") {
+			return fmt.Errorf("line %d: %s must end with ';'", i+1, key)
+		}
+	}
+	if group == "" {
+		return fmt.Errorf("missing [Desktop Entry] group")
+	}
+	for _, key := range []string{"Type", "Name", "Exec", "Icon", "Categories"} {
+		if keys[key] == "" {
+			return fmt.Errorf("missing %s key", key)
+		}
+	}
+	if keys["Type"] != "Application" {
+		return fmt.Errorf("type must be Application, found '%s'", keys["Type"])
+	}
+	return nil
+}
+
+// packageIcon returns the icon.png of the target folder, empty if not found
+func (builder *Builder) packageIcon() string {
+	iconPath := filepath.Join(builder.packagePath, builder.target, "icon.png")
+	if _, err := os.Stat(iconPath); err != nil {
+		log("WARNING", fmt.Sprintf("no icon for the package, add icon.png to the '%s' folder", builder.target))
+		return ""
+	}
+	return iconPath
+}
+
+// goOS returns the OS of the built binaries, GOOS allows to cross-build
+// desktop applications
+func goOS() string {
+	if goos := os.Getenv("GOOS"); goos != "" {
+		return goos
+	}
+	return runtime.GOOS
+}
+
+// goArch returns the architecture of the built binaries
+func goArch() string {
+	if arch := os.Getenv("GOARCH"); arch != "" {
+		return arch
+	}
+	return runtime.GOARCH
+}
+
+// packageLinux packages the Linux application in the given format
+func (builder *Builder) packageLinux(format string, binaryFile string) error {
+	switch format {
+	case "deb":
+		return builder.packageDeb(binaryFile)
+	case "appimage":
+		return builder.packageAppImage(binaryFile)
+	}
+	return newError(errUsage, nil, "unsupported package format '%s' for %s target", format, builder.target)
+}
+
+// shellQuote quotes a shell word if needed
+func shellQuote(word string) string {
+	if word != "" && strings.Trim(word, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=.,/:@+") == "" {
+		return word
+	}
+	return "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+}
+
+// goBuildCommand returns the go build shell command of the Linux application
+// with the flags of the build command
+func (builder *Builder) goBuildCommand(output string) string {
+	words := []string{"go", "build"}
+	flags := builder.mergeBuildFlags(builder.profileBuildFlags())
+	for _, param := range flags.params() {
+		words = append(words, shellQuote(param))
+	}
+	return strings.Join(append(words, "-o", shellQuote(output), "."), " ")
+}
+
+var packageCommand = &command{
+	name:     "package",
+	summary:  "Generate Flatpak or Snap packaging files of the Linux application",
+	synopsis: []string{"-format FORMAT [flags] [packagePath]"},
+	help: `Packaging files are generated from the project metadata, the package itself is
+built by the external tool:
+    flatpak     dist/flatpak/$ID.json manifest for flatpak-builder
+                (org.freedesktop.Platform runtime with Go SDK extension), with
+                access to display, GL, audio and input devices
+    snap        snap/snapcraft.yaml in the project folder for snapcraft (strict
+                confinement) with opengl, audio-playback and joystick plugs,
+                build it with 'snapcraft' from the project folder
+
+The application is built with go build, using the tags and flags of the build
+command for the given profile. The desktop entry and icons are generated from
+icon.png of the linux folder. Generation works offline, but flatpak-builder
+needs network access to download Go modules unless they are vendored with
+'go mod vendor'.`,
+	notes: `Metadata are set in the package section of the project manifest (see 'tge-cli
+help desktop'), along with specific settings:
+    {
+        "package": {
+            "id": "com.example.MyGame",
+            "flatpak": { "runtimeVersion": "23.08", "finishArgs": ["--socket=x11", "--device=dri"] },
+            "snap": {
+                "base": "core22", "confinement": "strict", "grade": "stable",
+                "plugs": ["x11", "opengl", "audio-playback"],
+                "buildPackages": ["gcc", "libgl1-mesa-dev", "xorg-dev"],
+                "stagePackages": ["libgl1"]
+            }
+        }
+    }`,
+	completions: map[string][]string{
+		"format":  manifestFormats,
+		"profile": {debugProfile, releaseProfile},
+	},
+	flags: func(fs *flag.FlagSet) {
+		fs.String("format", "", "packaging `format`, flatpak or snap")
+		fs.String("profile", "", "build `profile` of the application (see 'tge-cli help build')")
+		fs.String("tags", "", "comma separated build `tags`, added to the ones of the profile")
+	},
+	run: doPackage,
+}
+
+func doPackage(builder Builder, fs *flag.FlagSet, args []string) {
+	format := flagString(fs, "format")
+	if format == "" {
+		printCommandHelp("package")
+		return
+	}
+	if indexOf(manifestFormats, format) < 0 {
+		fail(newError(errUsage, nil, "unsupported package format '%s' (available: %s)", format, strings.Join(manifestFormats, ", ")))
+	}
+	packagePath := "."
+	if len(args) > 0 {
+		packagePath = args[0]
+	}
+	if tags := flagString(fs, "tags"); tags != "" {
+		builder.buildFlags.Tags = []string{tags}
+	}
+
+	builder.flags = flagSettings(fs, "profile")
+	if err := builder.readWorkspace(packagePath); err != nil {
+		fail(err)
+	}
+	profile, err := resolveProfile(builder.config.get("profile"), builder.manifest)
+	if err != nil {
+		fail(err)
+	}
+	builder.profile = profile
+	builder.target = "linux"
+
+	// snapcraft needs the project in its source tree, snap folder is written in
+	// the project folder
+	outPath := builder.packagePath
+	if format != "snap" {
+		outPath = filepath.Join(resolvePath(builder.packagePath, builder.config.get("dist")), format)
+		if err := os.RemoveAll(outPath); err != nil {
+			fail(err)
+		}
+		if err := os.MkdirAll(outPath, os.ModeDir|0755); err != nil {
+			fail(err)
+		}
+	}
+	buildCommand := builder.goBuildCommand(builder.packageMetadata().name)
+	switch format {
+	case "flatpak":
+		err = builder.writeFlatpak(outPath, buildCommand)
+	case "snap":
+		err = builder.writeSnap(outPath, buildCommand)
+	}
+	if err != nil {
+		fail(newError(errPackaging, err, "failed to generate %s packaging files", format))
+	}
+	log("SUCCESS", fmt.Sprintf("%s packaging files are available in %s", format, outPath))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const pluginPrefix = "tge-cli-"
+const pluginsPath = "bin"
+
+// plugin is an external subcommand, the tge-cli-<name> executable is found in
+// the project .tge/bin folder or in PATH.
+type plugin struct {
+	name string
+	path string
+}
+
+// findProject returns the workspace containing dir (the first parent folder
+// with a tge.json or go.mod file), empty if not in a workspace.
+func findProject(dir string) string {
+	for {
+		for _, file := range []string{manifestFile, "go.mod"} {
+			if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// pluginDirs returns the folders where plugins are searched, by priority
+func pluginDirs(projectPath string) []string {
+	var dirs []string
+	if projectPath != "" {
+		dirs = append(dirs, filepath.Join(projectPath, tgeLocalPath, pluginsPath))
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// pluginName returns the plugin name of an executable file name, empty if not
+// a plugin
+func pluginName(fileName string) string {
+	if !strings.HasPrefix(fileName, pluginPrefix) {
+		return ""
+	}
+	name := strings.TrimPrefix(fileName, pluginPrefix)
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext != ".exe" && ext != ".bat" && ext != ".cmd" {
+			return ""
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
+
+// listPlugins returns the plugins available from projectPath sorted by name,
+// a plugin in the project shadows the ones in PATH.
+func listPlugins(projectPath string) []plugin {
+	found := map[string]string{}
+	for _, dir := range pluginDirs(projectPath) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			name := pluginName(file.Name())
+			if _, exists := found[name]; name == "" || exists {
+				continue
+			}
+			if path := filepath.Join(dir, file.Name()); isExecutable(path) {
+				found[name] = path
+			}
+		}
+	}
+	plugins := make([]plugin, 0, len(found))
+	for name, path := range found {
+		plugins = append(plugins, plugin{name, path})
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].name < plugins[j].name })
+	return plugins
+}
+
+// lookupPlugin returns the plugin called name, nil if not found
+func lookupPlugin(projectPath string, name string) *plugin {
+	for _, p := range listPlugins(projectPath) {
+		if p.name == name {
+			return &p
+		}
+	}
+	return nil
+}
+
+// pluginEnviron returns the environment exposing the project context to
+// plugins, TGE is only looked up locally (vendor directory, replace directive
+// or modules cache).
+func (builder *Builder) pluginEnviron(projectPath string) []string {
+	var env []string
+	if executable, err := os.Executable(); err == nil {
+		env = append(env, fmt.Sprintf("TGE_CLI=%s", executable))
+	}
+	env = append(env, fmt.Sprintf("TGE_CLI_VERSION=%s", cliVersion))
+
+	if projectPath != "" {
+		if err := builder.loadConfig(projectPath); err != nil {
+			log("WARNING", err.Error())
+		}
+	}
+	if err := builder.loadGoEnv(); err == nil {
+		env = append(env, fmt.Sprintf("GOPATH=%s", builder.goPath), fmt.Sprintf("GOBIN=%s", builder.goBin))
+	}
+	if projectPath == "" {
+		return builder.environ(env...)
+	}
+
+	packageName := readModulePath(filepath.Join(projectPath, "go.mod"))
+	if packageName == "" {
+		packageName = filepath.Base(projectPath)
+	}
+	env = append(env,
+		fmt.Sprintf("TGE_PROJECT_PATH=%s", projectPath),
+		fmt.Sprintf("TGE_PROJECT_PACKAGE=%s", packageName),
+	)
+	if builder.config != nil {
+		env = append(env, fmt.Sprintf("TGE_PROJECT_DIST=%s", resolvePath(projectPath, builder.config.get("dist"))))
+	}
+
+	cmd := exec.Command("go", "list", "-m", "-e", "-json", tgePackageName)
+	cmd.Dir = projectPath
+	cmd.Env = builder.environ()
+	if output, err := cmd.Output(); err == nil {
+		info := moduleInfo{}
+		if json.Unmarshal(output, &info) == nil && info.Error == nil && info.Version != "" {
+			if info.Replace != nil {
+				info.Dir = info.Replace.Dir
+			}
+			vendorPath := filepath.Join(projectPath, "vendor", filepath.FromSlash(tgePackageName))
+			if _, err := os.Stat(vendorPath); err == nil {
+				info.Dir = vendorPath
+			}
+			env = append(env, fmt.Sprintf("TGE_PROJECT_TGE_VERSION=%s", info.Version))
+			if info.Dir != "" {
+				env = append(env, fmt.Sprintf("TGE_PROJECT_TGE_ROOT=%s", info.Dir))
+			}
+		}
+	}
+	return builder.environ(env...)
+}
+
+// runPlugin runs the plugin with args and exits with its exit code
+func (builder *Builder) runPlugin(p *plugin, args []string) {
+	cmd := exec.Command(p.path, args...)
+	cmd.Env = builder.pluginEnviron(findProject(builder.cwd))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Interruptions are handled by the plugin
+	signal.Ignore(os.Interrupt)
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		log("ERROR", fmt.Sprintf("failed to run plugin %s: %s", p.path, err))
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
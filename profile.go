@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const debugProfile = "debug"
+const releaseProfile = "release"
+
+// Profile defines a named set of build behaviours in the manifest, unset
+// fields are inherited from the extended profile (release by default).
+type Profile struct {
+	// Extends is the name of the profile used for unset fields
+	Extends string `json:"extends,omitempty"`
+	// Clean removes the dist folder of the target before building
+	Clean *bool `json:"clean,omitempty"`
+	// Assets copies the assets folder in dist on each build, when disabled
+	// assets are only copied if missing in dist
+	Assets *bool `json:"assets,omitempty"`
+	// UniversalAPK generates a single APK for all architectures on Android
+	UniversalAPK *bool `json:"universalApk,omitempty"`
+	// Console keeps desktop applications unpacked with console opened
+	Console *bool `json:"console,omitempty"`
+	// Debug adds the debug build tag
+	Debug *bool `json:"debug,omitempty"`
+	// Optimize enables compiler optimizations and inlining
+	Optimize *bool `json:"optimize,omitempty"`
+	// Strip removes symbol table and DWARF information from binaries
+	Strip *bool `json:"strip,omitempty"`
+	// Race enables the race detector (desktop targets only)
+	Race *bool `json:"race,omitempty"`
+	// Tags are additional build tags
+	Tags []string `json:"tags,omitempty"`
+}
+
+// buildProfile is a resolved profile
+type buildProfile struct {
+	name         string
+	clean        bool
+	assets       bool
+	universalAPK bool
+	console      bool
+	debug        bool
+	optimize     bool
+	strip        bool
+	race         bool
+	tags         []string
+}
+
+var builtinProfiles = map[string]buildProfile{
+	debugProfile: {
+		name:         debugProfile,
+		universalAPK: true,
+		console:      true,
+		debug:        true,
+		optimize:     true,
+	},
+	releaseProfile: {
+		name:     releaseProfile,
+		clean:    true,
+		assets:   true,
+		optimize: true,
+	},
+}
+
+// resolveProfile returns the profile name from built-in profiles and the ones
+// defined in the manifest, a manifest profile overrides a built-in one.
+func resolveProfile(name string, manifest *Manifest) (buildProfile, error) {
+	return resolveProfileFrom(name, manifest, map[string]bool{})
+}
+
+func resolveProfileFrom(name string, manifest *Manifest, visited map[string]bool) (buildProfile, error) {
+	var profile Profile
+	found := false
+	if manifest != nil {
+		profile, found = manifest.Profiles[name]
+	}
+	builtin, isBuiltin := builtinProfiles[name]
+	if !found {
+		if !isBuiltin {
+			return buildProfile{}, newError(errUsage, nil, "unknown profile '%s' (available: %s)", name, profileNames(manifest))
+		}
+		return builtin, nil
+	}
+	if visited[name] {
+		return buildProfile{}, newError(errProject, nil, "profile '%s' extends itself", name)
+	}
+	visited[name] = true
+
+	resolved := builtinProfiles[releaseProfile]
+	switch {
+	case profile.Extends != "":
+		var err error
+		if resolved, err = resolveProfileFrom(profile.Extends, manifest, visited); err != nil {
+			return buildProfile{}, err
+		}
+	case isBuiltin:
+		resolved = builtin
+	}
+
+	resolved.name = name
+	for _, field := range []struct {
+		value  *bool
+		target *bool
+	}{
+		{profile.Clean, &resolved.clean},
+		{profile.Assets, &resolved.assets},
+		{profile.UniversalAPK, &resolved.universalAPK},
+		{profile.Console, &resolved.console},
+		{profile.Debug, &resolved.debug},
+		{profile.Optimize, &resolved.optimize},
+		{profile.Strip, &resolved.strip},
+		{profile.Race, &resolved.race},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+		}
+	}
+	resolved.tags = append(append([]string{}, resolved.tags...), profile.Tags...)
+	return resolved, nil
+}
+
+// profileNames lists the available profiles
+func profileNames(manifest *Manifest) string {
+	names := []string{debugProfile, releaseProfile}
+	if manifest != nil {
+		for name := range manifest.Profiles {
+			if _, found := builtinProfiles[name]; !found {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// profileBuildFlags returns the build flags injected by the build profile
+func (builder *Builder) profileBuildFlags() goBuildFlags {
+	var flags goBuildFlags
+	if builder.profile.debug {
+		flags.addTags("debug")
+	}
+	for _, tags := range builder.profile.tags {
+		flags.addTags(tags)
+	}
+	if !builder.profile.optimize {
+		flags.gcflags = append(flags.gcflags, "all=-N -l")
+	}
+	if builder.profile.strip {
+		flags.ldflags = append(flags.ldflags, "-s -w")
+	}
+	if builder.profile.race {
+		switch builder.target {
+		case "darwin", "windows", "linux":
+			flags.args = append(flags.args, "-race")
+		default:
+			log("WARNING", fmt.Sprintf("race detector is not supported for %s target, ignored", builder.target))
+		}
+	}
+	return flags
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const webManifestFile = "manifest.webmanifest"
+const serviceWorkerFile = "sw.js"
+
+// pwaIconSizes are the sizes of the icons generated from browser/icon.png
+var pwaIconSizes = []int{192, 512}
+
+// PWAIcon is an icon of the web app manifest
+type PWAIcon struct {
+	// Src is the icon path relative to dist/browser
+	Src string `json:"src"`
+	// Sizes is the icon size (ex: 192x192)
+	Sizes string `json:"sizes"`
+	// Type is the MIME type of the icon, default is image/png
+	Type string `json:"type,omitempty"`
+}
+
+// webManifest is the web app manifest of a PWA
+type webManifest struct {
+	Name            string    `json:"name"`
+	ShortName       string    `json:"short_name"`
+	StartURL        string    `json:"start_url"`
+	Display         string    `json:"display"`
+	Orientation     string    `json:"orientation"`
+	ThemeColor      string    `json:"theme_color"`
+	BackgroundColor string    `json:"background_color"`
+	Icons           []PWAIcon `json:"icons,omitempty"`
+}
+
+// webManifest returns the web app manifest from the browser settings, the
+// icons are generated from browser/icon.png if not set.
+func (builder *Builder) webManifest() (*webManifest, error) {
+	vars := builder.browserPageVars()
+	manifest := &webManifest{
+		Name:            vars.Title,
+		ShortName:       vars.Title,
+		StartURL:        ".",
+		Display:         "fullscreen",
+		Orientation:     "any",
+		ThemeColor:      "#000000",
+		BackgroundColor: "#000000",
+	}
+	if builder.manifest != nil {
+		settings := builder.manifest.Browser
+		if settings.ShortName != "" {
+			manifest.ShortName = settings.ShortName
+		}
+		if settings.Display != "" {
+			manifest.Display = settings.Display
+		}
+		if settings.Orientation != "" {
+			manifest.Orientation = settings.Orientation
+		}
+		if settings.ThemeColor != "" {
+			manifest.ThemeColor = settings.ThemeColor
+		}
+		if settings.BackgroundColor != "" {
+			manifest.BackgroundColor = settings.BackgroundColor
+		}
+		manifest.Icons = settings.Icons
+	}
+
+	if len(manifest.Icons) == 0 {
+		iconPath := filepath.Join(builder.packagePath, builder.target, "icon.png")
+		if _, err := os.Stat(iconPath); os.IsNotExist(err) {
+			log("WARNING", fmt.Sprintf("no icon for the PWA, add icon.png to the '%s' folder or set browser icons in %s", builder.target, manifestFile))
+			return manifest, nil
+		}
+		for _, size := range pwaIconSizes {
+			name := fmt.Sprintf("icon-%d.png", size)
+			if err := resizePNG(iconPath, filepath.Join(builder.distPath, name), size); err != nil {
+				return nil, fmt.Errorf("failed to generate %s: %s", name, err)
+			}
+			manifest.Icons = append(manifest.Icons, PWAIcon{name, fmt.Sprintf("%dx%d", size, size), "image/png"})
+		}
+	}
+	for i := range manifest.Icons {
+		if manifest.Icons[i].Type == "" {
+			manifest.Icons[i].Type = "image/png"
+		}
+	}
+	return manifest, nil
+}
+
+// packagePWA writes the web app manifest and the service worker in dist, both
+// are registered in index.html.
+func (builder *Builder) packagePWA() error {
+	log("NOTICE", "Generating PWA manifest and service worker")
+	manifest, err := builder.webManifest()
+	if err != nil {
+		return err
+	}
+	content, _ := json.MarshalIndent(manifest, "", "  ")
+	if err = ioutil.WriteFile(filepath.Join(builder.distPath, webManifestFile), content, 0644); err != nil {
+		return err
+	}
+	if err = builder.injectPWATags(manifest.ThemeColor); err != nil {
+		return err
+	}
+
+	files, version, err := builder.precacheFiles()
+	if err != nil {
+		return err
+	}
+	worker := strings.NewReplacer(
+		"{{CACHE}}", fmt.Sprintf("%s-%s", builder.programName, version),
+		"{{PREFIX}}", builder.programName+"-",
+		"{{FILES}}", strings.Join(files, ",\n    "),
+	).Replace(serviceWorkerTemplate)
+	return ioutil.WriteFile(filepath.Join(builder.distPath, serviceWorkerFile), []byte(worker), 0644)
+}
+
+// injectPWATags adds the manifest link and the service worker registration to
+// index.html, tags already present are kept.
+func (builder *Builder) injectPWATags(themeColor string) error {
+	pagePath := filepath.Join(builder.distPath, browserPageFile)
+	content, err := ioutil.ReadFile(pagePath)
+	if err != nil {
+		return err
+	}
+	page := string(content)
+	if !strings.Contains(page, webManifestFile) {
+		tags := fmt.Sprintf("    <link rel=\"manifest\" href=\"%s\">\n    <meta name=\"theme-color\" content=\"%s\">\n", webManifestFile, themeColor)
+		page = insertBefore(page, "</head>", tags)
+	}
+	if !strings.Contains(page, serviceWorkerFile) {
+		script := fmt.Sprintf("    <script>\n        if (\"serviceWorker\" in navigator) {\n            navigator.serviceWorker.register(\"%s\");\n        }\n    </script>\n", serviceWorkerFile)
+		page = insertBefore(page, "</body>", script)
+	}
+	return ioutil.WriteFile(pagePath, []byte(page), 0644)
+}
+
+// insertBefore inserts text before the last occurrence of tag, at the end of
+// page if not found
+func insertBefore(page string, tag string, text string) string {
+	index := strings.LastIndex(strings.ToLower(page), tag)
+	if index < 0 {
+		return page + text
+	}
+	return page[:index] + text + page[index:]
+}
+
+// precacheFiles returns the quoted URLs of the files in dist cached by the
+// service worker and a version hashed from their contents.
+func (builder *Builder) precacheFiles() ([]string, string, error) {
+	var paths []string
+	err := filepath.Walk(builder.distPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, _ := filepath.Rel(builder.distPath, p)
+		if relPath != serviceWorkerFile {
+			paths = append(paths, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	files := []string{`"./"`}
+	for _, p := range paths {
+		f, err := os.Open(filepath.Join(builder.distPath, filepath.FromSlash(p)))
+		if err != nil {
+			return nil, "", err
+		}
+		io.WriteString(hash, p)
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		files = append(files, fmt.Sprintf("%q", p))
+	}
+	return files, hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+const serviceWorkerTemplate = `// Service worker generated by tge-cli, the cache name changes with the
+// content of the cached files.
+const CACHE = "{{CACHE}}";
+const FILES = [
+    {{FILES}}
+];
+
+self.addEventListener("install", event => {
+    event.waitUntil(caches.open(CACHE).then(cache => cache.addAll(FILES)));
+    self.skipWaiting();
+});
+
+self.addEventListener("activate", event => {
+    event.waitUntil(caches.keys().then(keys => Promise.all(
+        keys.filter(key => key.startsWith("{{PREFIX}}") && key !== CACHE).map(key => caches.delete(key))
+    )));
+    self.clients.claim();
+});
+
+self.addEventListener("fetch", event => {
+    if (event.request.method !== "GET") {
+        return;
+    }
+    event.respondWith(caches.match(event.request).then(cached => cached || fetch(event.request)));
+});
+`
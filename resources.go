@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// resourcesDiff lists the differences between a target resources folder and
+// its TGE template, paths are relative to the folder.
+type resourcesDiff struct {
+	target   string
+	added    []string
+	removed  []string
+	modified []string
+	diffs    []string
+}
+
+// renderPristineResources renders the TGE template of target in a temporary
+// folder, the caller is in charge of removing it.
+func (builder *Builder) renderPristineResources(target string) (string, error) {
+	templatePath := filepath.Join(builder.tgeRootPath, tgeTemplatePath, target)
+	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+		return "", fmt.Errorf("'%s' template not found in TGE %s", target, builder.tgeProjectVersion)
+	}
+	pristinePath, err := ioutil.TempDir("", fmt.Sprintf("tge-resources-%s-", target))
+	if err != nil {
+		return "", err
+	}
+	if _, err = renderTemplateDir(templatePath, pristinePath, builder.templateVars(), true); err != nil {
+		removeAll(pristinePath)
+		return "", fmt.Errorf("failed to render '%s' template: %s", target, err)
+	}
+	return pristinePath, nil
+}
+
+func (builder *Builder) diffResources(target string) (*resourcesDiff, error) {
+	pristinePath, err := builder.renderPristineResources(target)
+	if err != nil {
+		return nil, err
+	}
+	defer removeAll(pristinePath)
+
+	resourcesPath := filepath.Join(builder.packagePath, target)
+	pristineFiles, resourcesFiles := listFiles(pristinePath), listFiles(resourcesPath)
+	relPaths := map[string]bool{}
+	for relPath := range pristineFiles {
+		relPaths[relPath] = true
+	}
+	for relPath := range resourcesFiles {
+		relPaths[relPath] = true
+	}
+	sortedPaths := make([]string, 0, len(relPaths))
+	for relPath := range relPaths {
+		sortedPaths = append(sortedPaths, relPath)
+	}
+	sort.Strings(sortedPaths)
+
+	diff := &resourcesDiff{target: target}
+	for _, relPath := range sortedPaths {
+		pristine, _ := readOptionalFile(filepath.Join(pristinePath, relPath))
+		resource, _ := readOptionalFile(filepath.Join(resourcesPath, relPath))
+		switch {
+		case !resourcesFiles[relPath]:
+			diff.removed = append(diff.removed, relPath)
+		case !pristineFiles[relPath]:
+			diff.added = append(diff.added, relPath)
+		case bytes.Equal(pristine, resource):
+			continue
+		default:
+			diff.modified = append(diff.modified, relPath)
+		}
+
+		nameA, nameB := filepath.ToSlash(filepath.Join("template", target, relPath)), filepath.ToSlash(filepath.Join(target, relPath))
+		if !pristineFiles[relPath] {
+			nameA = "/dev/null"
+		}
+		if !resourcesFiles[relPath] {
+			nameB = "/dev/null"
+		}
+		if isBinary(pristine) || isBinary(resource) {
+			diff.diffs = append(diff.diffs, fmt.Sprintf("Binary files %s and %s differ\n", nameA, nameB))
+		} else {
+			diff.diffs = append(diff.diffs, unifiedDiff(nameA, nameB, pristine, resource))
+		}
+	}
+	return diff, nil
+}
+
+// diffTargets compares the resources folders of the project with their TGE
+// templates, all existing folders are compared if target is empty.
+func (builder *Builder) diffTargets(target string) ([]*resourcesDiff, error) {
+	targets := tgeTargets
+	if target != "" {
+		targets = []string{target}
+	}
+	var diffs []*resourcesDiff
+	for _, t := range targets {
+		if _, err := os.Stat(filepath.Join(builder.packagePath, t)); os.IsNotExist(err) {
+			if target != "" {
+				return nil, newError(errProject, nil, "'%s' folder not found in %s", t, builder.packagePath)
+			}
+			continue
+		}
+		diff, err := builder.diffResources(t)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+// resetResources restores the pristine template files of target, all template
+// files are restored if files is empty. Files added in the folder are kept.
+func (builder *Builder) resetResources(target string, files []string) ([]string, error) {
+	pristinePath, err := builder.renderPristineResources(target)
+	if err != nil {
+		return nil, err
+	}
+	defer removeAll(pristinePath)
+
+	if len(files) == 0 {
+		for relPath := range listFiles(pristinePath) {
+			files = append(files, relPath)
+		}
+		sort.Strings(files)
+	}
+
+	resourcesPath := filepath.Join(builder.packagePath, target)
+	var restored []string
+	for _, file := range files {
+		relPath := filepath.Clean(strings.TrimPrefix(filepath.ToSlash(file), target+"/"))
+		if filepath.IsAbs(relPath) || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			return restored, newError(errUsage, nil, "'%s' is outside of '%s' folder", file, target)
+		}
+		content, found := readOptionalFile(filepath.Join(pristinePath, relPath))
+		if !found {
+			return restored, fmt.Errorf("'%s' not found in '%s' template", relPath, target)
+		}
+		if current, _ := readOptionalFile(filepath.Join(resourcesPath, relPath)); current != nil && bytes.Equal(current, content) {
+			continue
+		}
+		if err = writeFileAll(filepath.Join(resourcesPath, relPath), content); err != nil {
+			return restored, err
+		}
+		restored = append(restored, relPath)
+	}
+
+	if _, err := os.Stat(builder.resourcesBasePath(target)); os.IsNotExist(err) {
+		if err = builder.saveResourcesBase(target); err != nil {
+			log("WARNING", fmt.Sprintf("failed to save '%s' template for upgrades: %s", target, err))
+		}
+	}
+	return restored, nil
+}
+
+// listFiles returns the relative paths of the files under root
+func listFiles(root string) map[string]bool {
+	files := map[string]bool{}
+	filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			if relPath, err := filepath.Rel(root, p); err == nil {
+				files[relPath] = true
+			}
+		}
+		return nil
+	})
+	return files
+}
+
+func (diff *resourcesDiff) print() {
+	fmt.Printf("%s/\n", diff.target)
+	entries := []struct {
+		label string
+		files []string
+	}{
+		{"added", diff.added},
+		{"removed", diff.removed},
+		{"modified", diff.modified},
+	}
+	for _, entry := range entries {
+		for _, file := range entry.files {
+			fmt.Printf("    %-10s %s\n", entry.label, file)
+		}
+	}
+	if len(diff.diffs) == 0 {
+		fmt.Println("    no changes")
+	}
+	fmt.Println()
+	for _, d := range diff.diffs {
+		fmt.Print(d)
+	}
+}
+
+var resourcesCommand = &command{
+	name:        "resources",
+	summary:     "Compare and restore target resources folders with TGE templates",
+	synopsis:    []string{"diff [flags] [packagePath]", "reset -target TARGET [flags] [packagePath [files...]]"},
+	subcommands: []string{"diff", "reset"},
+	help: `diff        compares each target folder of the project (android, ios, browser,
+            darwin, windows, linux) with its TGE template at the version
+            required by the project. Added, removed and modified files are
+            listed followed by unified diffs.
+
+reset       restores pristine template files in the target folder, all template
+            files are restored if no files are given. Files added in the folder
+            are kept.`,
+	completions: map[string][]string{
+		"target":   tgeTargets,
+		"tge-path": dirsCompletion,
+	},
+	flags: func(fs *flag.FlagSet) {
+		fs.String("target", "", "target resources `folder`, default all for diff")
+		offlineFlag(fs)
+		tgePathFlag(fs)
+	},
+	run: doResources,
+}
+
+func doResources(builder Builder, fs *flag.FlagSet, args []string) {
+	if len(args) == 0 {
+		printCommandHelp("resources")
+		return
+	}
+	command := args[0]
+	target := flagString(fs, "target")
+
+	packagePath := "."
+	if len(args) > 1 {
+		packagePath = args[1]
+	}
+
+	builder.flags = flagSettings(fs, "offline", "tge-path")
+	if target != "" {
+		if err := checkTargets([]string{target}); err != nil {
+			fail(err)
+		}
+	}
+
+	switch command {
+	case "diff":
+		if err := builder.openWorkspace(packagePath); err != nil {
+			fail(err)
+		}
+		diffs, err := builder.diffTargets(target)
+		if err != nil {
+			fail(err)
+		}
+		for _, diff := range diffs {
+			diff.print()
+		}
+
+	case "reset":
+		if target == "" {
+			fail(newError(errUsage, nil, "missing target to reset (set with -target)"))
+		}
+		if err := builder.openWorkspace(packagePath); err != nil {
+			fail(err)
+		}
+		var files []string
+		if len(args) > 2 {
+			files = args[2:]
+		}
+		restored, err := builder.resetResources(target, files)
+		for _, file := range restored {
+			fmt.Printf("    %-10s %s\n", "restored", file)
+		}
+		if err != nil {
+			fail(err)
+		}
+		log("SUCCESS", fmt.Sprintf("%d files restored in '%s' folder", len(restored), target))
+
+	default:
+		fail(newError(errUsage, nil, "unknown resources command '%s' (see 'tge-cli help resources')", command))
+	}
+}
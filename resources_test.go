@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTargets(t *testing.T) {
+	tgeRootPath, packagePath := t.TempDir(), t.TempDir()
+	for path, content := range map[string]string{
+		filepath.Join(tgeRootPath, tgeTemplatePath, "android", "AndroidManifest.xml"): "<manifest/>\n",
+		filepath.Join(tgeRootPath, tgeTemplatePath, "browser", "index.html"):          "<html/>\n",
+		filepath.Join(tgeRootPath, tgeTemplatePath, "browser", "wasm_exec.js"):        "exec\n",
+		filepath.Join(packagePath, "browser", "index.html"):                           "<html></html>\n",
+		filepath.Join(packagePath, "browser", "main.css"):                             "body {}\n",
+	} {
+		if err := writeFileAll(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	builder := Builder{packageName: "example.com/demo", packagePath: packagePath, tgeRootPath: tgeRootPath}
+
+	diffs, err := builder.diffTargets("")
+	if err != nil {
+		t.Fatalf("diffTargets() error = %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].target != "browser" {
+		t.Fatalf("diffTargets() = %v, want browser only", diffs)
+	}
+	diff := diffs[0]
+	if got := [][]string{diff.added, diff.removed, diff.modified}; !reflect.DeepEqual(got, [][]string{{"main.css"}, {"wasm_exec.js"}, {"index.html"}}) {
+		t.Errorf("browser added, removed, modified = %q", got)
+	}
+
+	if _, err := builder.diffTargets("browser"); err != nil {
+		t.Errorf("diffTargets(browser) error = %v", err)
+	}
+	if _, err := builder.diffTargets("android"); err == nil {
+		t.Errorf("diffTargets(android) without folder succeeded")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultSingleFileMaxSize is the size of the single HTML file above which a
+// warning is logged
+const defaultSingleFileMaxSize = "20MB"
+
+var wasmExecScriptPattern = regexp.MustCompile(`<script[^>]*\ssrc="` + regexp.QuoteMeta(wasmExecFile) + `"[^>]*>\s*</script>`)
+var linkPattern = regexp.MustCompile(`<link\b[^>]*>`)
+var hrefPattern = regexp.MustCompile(`\shref="([^"]+)"`)
+
+// singleFilePath returns the path of the single HTML file, next to the browser
+// folder in dist
+func (builder *Builder) singleFilePath() string {
+	return filepath.Join(filepath.Dir(builder.distPath), builder.programName+".html")
+}
+
+// packageSingleFile writes the application as a single HTML file: wasm_exec.js
+// and stylesheets are inlined and the other files of dist (main.wasm, assets...)
+// are stored gzipped in a virtual file table served to fetch().
+func (builder *Builder) packageSingleFile() error {
+	outPath := builder.singleFilePath()
+	log("NOTICE", fmt.Sprintf("Generating single HTML file: %s", outPath))
+	content, err := ioutil.ReadFile(filepath.Join(builder.distPath, browserPageFile))
+	if err != nil {
+		return err
+	}
+	page := string(content)
+
+	wasmExec, err := ioutil.ReadFile(filepath.Join(builder.distPath, wasmExecFile))
+	if err != nil {
+		return err
+	}
+	if !wasmExecScriptPattern.MatchString(page) {
+		return fmt.Errorf("%s script not found in %s", wasmExecFile, browserPageFile)
+	}
+	page = wasmExecScriptPattern.ReplaceAllLiteralString(page, "<script>\n"+escapeScript(string(wasmExec))+"\n</script>")
+
+	inlined := map[string]bool{browserPageFile: true, wasmExecFile: true}
+	page = linkPattern.ReplaceAllStringFunc(page, func(link string) string {
+		href := hrefPattern.FindStringSubmatch(link)
+		if href == nil || !strings.Contains(link, `rel="stylesheet"`) {
+			return link
+		}
+		css, err := ioutil.ReadFile(filepath.Join(builder.distPath, filepath.FromSlash(href[1])))
+		if err != nil {
+			return link
+		}
+		inlined[href[1]] = true
+		return "<style>\n" + strings.Replace(string(css), "</style", `<\/style`, -1) + "\n</style>"
+	})
+
+	table, err := builder.virtualFileTable(inlined)
+	if err != nil {
+		return err
+	}
+	page = insertAfterHead(page, "<script>\n"+strings.Replace(virtualFilesScript, "{{FILES}}", table, 1)+"</script>\n")
+	if err = ioutil.WriteFile(outPath, []byte(page), 0644); err != nil {
+		return err
+	}
+
+	maxSize := defaultSingleFileMaxSize
+	if builder.manifest != nil && builder.manifest.Browser.SingleFileMaxSize != "" {
+		maxSize = builder.manifest.Browser.SingleFileMaxSize
+	}
+	max, err := parseSize(maxSize)
+	if err != nil {
+		return newError(errProject, err, "invalid browser singleFileMaxSize")
+	}
+	if size := int64(len(page)); size > max {
+		log("WARNING", fmt.Sprintf("single HTML file is %s, more than %s (set browser singleFileMaxSize in %s)", formatSize(size), formatSize(max), manifestFile))
+	}
+	return nil
+}
+
+// virtualFileTable returns the JSON object mapping the paths of the dist files
+// not inlined to their gzipped and base64 encoded contents
+func (builder *Builder) virtualFileTable(inlined map[string]bool) (string, error) {
+	files := map[string]string{}
+	err := filepath.Walk(builder.distPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, _ := filepath.Rel(builder.distPath, p)
+		relPath = filepath.ToSlash(relPath)
+		if inlined[relPath] {
+			return nil
+		}
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		zw.Write(content)
+		zw.Close()
+		files[relPath] = base64.StdEncoding.EncodeToString(buf.Bytes())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	entries := make([]string, 0, len(paths))
+	for _, p := range paths {
+		name, _ := json.Marshal(p)
+		entries = append(entries, fmt.Sprintf("%s: \"%s\"", name, files[p]))
+	}
+	return "{\n        " + strings.Join(entries, ",\n        ") + "\n    }", nil
+}
+
+// escapeScript prevents the end of an inline script before its end
+func escapeScript(script string) string {
+	return strings.Replace(script, "</script", `<\/script`, -1)
+}
+
+// insertAfterHead inserts text after the <head> tag, at the beginning of page if
+// not found
+func insertAfterHead(page string, text string) string {
+	index := strings.Index(strings.ToLower(page), "<head>")
+	if index < 0 {
+		return text + page
+	}
+	index += len("<head>")
+	return page[:index] + "\n" + text + page[index:]
+}
+
+const virtualFilesScript = `// Virtual files generated by tge-cli, they are decompressed at startup and
+// served to fetch() in place of the network.
+(() => {
+    const table = {{FILES}};
+    const types = { wasm: "application/wasm", json: "application/json", css: "text/css", js: "text/javascript", png: "image/png", jpg: "image/jpeg", svg: "image/svg+xml" };
+    const decode = data => {
+        const bytes = Uint8Array.from(atob(data), c => c.charCodeAt(0));
+        return new Response(new Blob([bytes]).stream().pipeThrough(new DecompressionStream("gzip"))).arrayBuffer();
+    };
+    const files = Promise.all(Object.keys(table).map(name => decode(table[name]).then(content => [name, content])))
+        .then(entries => new Map(entries));
+    const base = new URL(".", document.baseURI).href;
+    const fetchNetwork = window.fetch.bind(window);
+    window.fetch = (input, init) => {
+        const url = new URL(typeof input === "string" ? input : input.url, document.baseURI).href;
+        const name = decodeURIComponent(url.startsWith(base) ? url.slice(base.length).split(/[?#]/)[0] : "");
+        return files.then(files => {
+            if (!files.has(name)) {
+                return fetchNetwork(input, init);
+            }
+            const type = types[name.split(".").pop().toLowerCase()] || "application/octet-stream";
+            return new Response(files.get(name), { headers: { "Content-Type": type } });
+        });
+    };
+})();
+`
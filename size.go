@@ -0,0 +1,400 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxReportedPackages is the number of packages listed by binary, the others
+// are summed
+const maxReportedPackages = 15
+
+// SizeBudget limits the size of the files generated in dist, sizes are written
+// in bytes or with a unit (ex: 500KB, 2MB).
+type SizeBudget struct {
+	// Total is the budget of all files
+	Total string `json:"total,omitempty"`
+	// Assets is the budget of the assets folder
+	Assets string `json:"assets,omitempty"`
+	// Files maps file patterns relative to dist/<target> (ex: main.wasm,
+	// *.apk) to their budget
+	Files map[string]string `json:"files,omitempty"`
+	// Gzip applies budgets to gzipped sizes
+	Gzip bool `json:"gzip,omitempty"`
+}
+
+var sizePattern = regexp.MustCompile(`^(?i)\s*([0-9]+(?:\.[0-9]+)?)\s*(b|kb|mb|gb)?\s*$`)
+
+// parseSize returns the number of bytes of a size like 500KB or 2MB, units are
+// powers of 1024.
+func parseSize(size string) (int64, error) {
+	match := sizePattern.FindStringSubmatch(size)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size '%s' (ex: 500KB, 2MB)", size)
+	}
+	value, _ := strconv.ParseFloat(match[1], 64)
+	switch strings.ToLower(match[2]) {
+	case "kb":
+		value *= 1 << 10
+	case "mb":
+		value *= 1 << 20
+	case "gb":
+		value *= 1 << 30
+	}
+	return int64(value), nil
+}
+
+// validate checks sizes and patterns of the budget
+func (budget *SizeBudget) validate(origin string) error {
+	if budget == nil {
+		return nil
+	}
+	sizes := []string{budget.Total, budget.Assets}
+	for pattern, size := range budget.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return newError(errProject, nil, "invalid budget file pattern '%s' in %s", pattern, origin)
+		}
+		sizes = append(sizes, size)
+	}
+	for _, size := range sizes {
+		if size == "" {
+			continue
+		}
+		if _, err := parseSize(size); err != nil {
+			return newError(errProject, err, "invalid budget in %s", origin)
+		}
+	}
+	return nil
+}
+
+// formatSize returns a human readable size
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// fileSize is the raw and gzipped size of a file or a group of files
+type fileSize struct {
+	Path  string `json:"path"`
+	Files int    `json:"files,omitempty"`
+	Size  int64  `json:"size"`
+	Gzip  int64  `json:"gzip"`
+}
+
+func (s *fileSize) add(other fileSize) {
+	s.Files += other.Files
+	s.Size += other.Size
+	s.Gzip += other.Gzip
+}
+
+// packageSize is the contribution of a Go package to a binary
+type packageSize struct {
+	Package string `json:"package"`
+	Size    int64  `json:"size"`
+}
+
+// sizeReport lists the sizes of the files generated in dist/<target>, assets
+// are grouped and binaries are detailed by package.
+type sizeReport struct {
+	Target   string                   `json:"target"`
+	Profile  string                   `json:"profile"`
+	Files    []fileSize               `json:"files"`
+	Assets   fileSize                 `json:"assets"`
+	Total    fileSize                 `json:"total"`
+	Packages map[string][]packageSize `json:"packages,omitempty"`
+}
+
+// sizeBudget returns the budget of the current target, desktop budget is used
+// for darwin, windows and linux if not set.
+func (builder *Builder) sizeBudget() *SizeBudget {
+	if builder.manifest == nil {
+		return nil
+	}
+	if budget := builder.manifest.Targets[builder.target].Budget; budget != nil {
+		return budget
+	}
+	switch builder.target {
+	case "darwin", "windows", "linux":
+		return builder.manifest.Targets["desktop"].Budget
+	}
+	return nil
+}
+
+// checkSizes prints the size report if asked, compared to the report at
+// previousPath if set, and checks the size budget of the target.
+func (builder *Builder) checkSizes(printReport bool, previousPath string) error {
+	budget := builder.sizeBudget()
+	if previousPath != "" {
+		printReport = true
+	}
+	if !printReport && budget == nil {
+		return nil
+	}
+
+	report, err := builder.buildSizeReport(printReport)
+	if err != nil {
+		return newError(errPackaging, err, "failed to compute sizes of %s", builder.distPath)
+	}
+	if printReport {
+		var previous *sizeReport
+		if previousPath != "" {
+			if previous, err = loadSizeReport(resolvePath(builder.cwd, previousPath)); err != nil {
+				return err
+			}
+		}
+		report.print(builder.distPath, previous)
+		reportPath := filepath.Join(filepath.Dir(builder.distPath), builder.target+"-size.json")
+		content, _ := json.MarshalIndent(report, "", "  ")
+		if err := ioutil.WriteFile(reportPath, content, 0644); err != nil {
+			log("WARNING", fmt.Sprintf("failed to save size report: %s", err))
+		} else {
+			log("NOTICE", fmt.Sprintf("size report saved in %s", reportPath))
+		}
+	}
+	return report.checkBudget(budget)
+}
+
+func (builder *Builder) buildSizeReport(withPackages bool) (*sizeReport, error) {
+	report := &sizeReport{
+		Target:   builder.target,
+		Profile:  builder.profile.name,
+		Assets:   fileSize{Path: assetsPath},
+		Total:    fileSize{Path: "total"},
+		Packages: map[string][]packageSize{},
+	}
+	assetsOutPath := filepath.Join(builder.distPath, assetsPath)
+	err := filepath.Walk(builder.distPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, _ := filepath.Rel(builder.distPath, p)
+		size, err := measureFile(p)
+		if err != nil {
+			return err
+		}
+		size.Path = filepath.ToSlash(relPath)
+		report.Total.add(size)
+		if strings.HasPrefix(p, assetsOutPath+string(filepath.Separator)) {
+			report.Assets.add(size)
+			return nil
+		}
+		report.Files = append(report.Files, size)
+
+		if withPackages {
+			syms, err := binarySymbols(p)
+			if err == errNoSymbols {
+				log("WARNING", fmt.Sprintf("%s has no symbol table (stripped), packages sizes not available", size.Path))
+			} else if err != nil {
+				log("WARNING", fmt.Sprintf("failed to read symbols of %s: %s", size.Path, err))
+			} else if len(syms) > 0 {
+				report.Packages[size.Path] = packagesSizes(syms)
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// measureFile returns the raw and gzipped size of the file at path
+func measureFile(path string) (fileSize, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileSize{}, err
+	}
+	defer f.Close()
+	counter := &countWriter{}
+	zw, _ := gzip.NewWriterLevel(counter, gzip.DefaultCompression)
+	size, err := io.Copy(zw, f)
+	if err != nil {
+		return fileSize{}, err
+	}
+	zw.Close()
+	return fileSize{Files: 1, Size: size, Gzip: counter.count}, nil
+}
+
+type countWriter struct {
+	count int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.count += int64(len(p))
+	return len(p), nil
+}
+
+// packagesSizes sums symbols sizes by package, sorted by size
+func packagesSizes(syms []symbolSize) []packageSize {
+	sums := map[string]int64{}
+	for _, sym := range syms {
+		sums[symbolPackage(sym.name)] += int64(sym.size)
+	}
+	sizes := make([]packageSize, 0, len(sums))
+	for pkg, size := range sums {
+		sizes = append(sizes, packageSize{pkg, size})
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].Size != sizes[j].Size {
+			return sizes[i].Size > sizes[j].Size
+		}
+		return sizes[i].Package < sizes[j].Package
+	})
+	return sizes
+}
+
+func loadSizeReport(path string) (*sizeReport, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, newError(errUsage, err, "failed to read size report")
+	}
+	report := &sizeReport{}
+	if err = json.Unmarshal(content, report); err != nil {
+		return nil, newError(errUsage, err, "invalid size report %s", path)
+	}
+	return report, nil
+}
+
+// sizeDiff returns the difference between size and a previous size
+func sizeDiff(size int64, previous int64, found bool) string {
+	switch {
+	case !found:
+		return "new"
+	case size == previous:
+		return "="
+	case size > previous:
+		return "+" + formatSize(size-previous)
+	}
+	return "-" + formatSize(previous-size)
+}
+
+func (report *sizeReport) print(distPath string, previous *sizeReport) {
+	previousFiles := map[string]int64{}
+	if previous != nil {
+		for _, file := range previous.Files {
+			previousFiles[file.Path] = file.Size
+		}
+		previousFiles[assetsPath+"/"] = previous.Assets.Size
+		previousFiles["total/"] = previous.Total.Size
+	}
+	printLine := func(name string, size fileSize, key string) {
+		line := fmt.Sprintf("    %-40s %10s %10s", name, formatSize(size.Size), formatSize(size.Gzip))
+		if previous != nil {
+			previousSize, found := previousFiles[key]
+			line += fmt.Sprintf(" %10s", sizeDiff(size.Size, previousSize, found))
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	log("NOTICE", fmt.Sprintf("size report of %s", distPath))
+	header := fmt.Sprintf("    %-40s %10s %10s", "FILE", "SIZE", "GZIP")
+	if previous != nil {
+		header += fmt.Sprintf(" %10s", "DIFF")
+	}
+	fmt.Println(header)
+	for _, file := range report.Files {
+		printLine(file.Path, file, file.Path)
+	}
+	printLine(fmt.Sprintf("%s (%d files)", assetsPath, report.Assets.Files), report.Assets, assetsPath+"/")
+	printLine("total", report.Total, "total/")
+
+	binaries := make([]string, 0, len(report.Packages))
+	for binary := range report.Packages {
+		binaries = append(binaries, binary)
+	}
+	sort.Strings(binaries)
+	for _, binary := range binaries {
+		previousPackages := map[string]int64{}
+		if previous != nil {
+			for _, pkg := range previous.Packages[binary] {
+				previousPackages[pkg.Package] = pkg.Size
+			}
+		}
+		var total int64
+		for _, pkg := range report.Packages[binary] {
+			total += pkg.Size
+		}
+
+		fmt.Println()
+		header := fmt.Sprintf("    %-40s %10s %10s", "PACKAGES OF "+binary, "SIZE", "SHARE")
+		if previous != nil {
+			header += fmt.Sprintf(" %10s", "DIFF")
+		}
+		fmt.Println(header)
+		packages := report.Packages[binary]
+		for i, pkg := range packages {
+			if i == maxReportedPackages {
+				var others int64
+				for _, pkg := range packages[i:] {
+					others += pkg.Size
+				}
+				fmt.Printf("    %-40s %10s %9.1f%%\n", fmt.Sprintf("(%d other packages)", len(packages)-i), formatSize(others), float64(others)*100/float64(total))
+				break
+			}
+			line := fmt.Sprintf("    %-40s %10s %9.1f%%", pkg.Package, formatSize(pkg.Size), float64(pkg.Size)*100/float64(total))
+			if previous != nil {
+				previousSize, found := previousPackages[pkg.Package]
+				line += fmt.Sprintf(" %10s", sizeDiff(pkg.Size, previousSize, found))
+			}
+			fmt.Println(line)
+		}
+	}
+	fmt.Println()
+}
+
+// checkBudget returns an errBudget error listing the budgets exceeded
+func (report *sizeReport) checkBudget(budget *SizeBudget) error {
+	if budget == nil {
+		return nil
+	}
+	kind := "size"
+	measure := func(size fileSize) int64 {
+		if budget.Gzip {
+			return size.Gzip
+		}
+		return size.Size
+	}
+	if budget.Gzip {
+		kind = "gzipped size"
+	}
+
+	var exceeded []string
+	check := func(name string, size int64, limit string) {
+		if limit == "" {
+			return
+		}
+		if max, _ := parseSize(limit); size > max {
+			exceeded = append(exceeded, fmt.Sprintf("%s %s > %s", name, formatSize(size), formatSize(max)))
+		}
+	}
+	check("total", measure(report.Total), budget.Total)
+	check(assetsPath, measure(report.Assets), budget.Assets)
+	patterns := make([]string, 0, len(budget.Files))
+	for pattern := range budget.Files {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		for _, file := range report.Files {
+			if matched, _ := filepath.Match(pattern, file.Path); matched {
+				check(file.Path, measure(file), budget.Files[pattern])
+			}
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return newError(errBudget, nil, "%s budget of %s target exceeded: %s", kind, report.Target, strings.Join(exceeded, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size  string
+		bytes int64
+		err   bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"100B", 100, false},
+		{"500KB", 500 << 10, false},
+		{"2MB", 2 << 20, false},
+		{"1.5mb", 3 << 19, false},
+		{"1GB", 1 << 30, false},
+		{" 8 MB ", 8 << 20, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"-1KB", 0, true},
+		{"2TB", 0, true},
+		{"1,5MB", 0, true},
+	}
+	for _, test := range tests {
+		bytes, err := parseSize(test.size)
+		if (err != nil) != test.err {
+			t.Errorf("parseSize(%q) error = %v, want error %t", test.size, err, test.err)
+		} else if bytes != test.bytes {
+			t.Errorf("parseSize(%q) = %d, want %d", test.size, bytes, test.bytes)
+		}
+	}
+}
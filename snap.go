@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SnapSettings holds the settings of snapcraft.yaml
+type SnapSettings struct {
+	// Base is the base snap, default is core22
+	Base string `json:"base,omitempty"`
+	// Confinement is strict (default), classic or devmode
+	Confinement string `json:"confinement,omitempty"`
+	// Grade is stable or devel, default is devel for debug profiles and
+	// stable otherwise
+	Grade string `json:"grade,omitempty"`
+	// Plugs are the interfaces of the application, default gives access to
+	// the display, GL, audio and joysticks
+	Plugs []string `json:"plugs,omitempty"`
+	// BuildPackages are the Debian packages needed to build the application
+	BuildPackages []string `json:"buildPackages,omitempty"`
+	// StagePackages are the Debian packages shipped with the application
+	StagePackages []string `json:"stagePackages,omitempty"`
+}
+
+var defaultSnapPlugs = []string{"desktop", "x11", "wayland", "opengl", "audio-playback", "joystick"}
+var defaultSnapBuildPackages = []string{"gcc", "libgl1-mesa-dev", "xorg-dev", "libasound2-dev"}
+var defaultSnapStagePackages = []string{"libgl1", "libasound2"}
+
+var snapNamePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// snapName returns name as a valid snap name: lowercase letters, digits and
+// single hyphens, up to 40 characters with at least one letter
+func snapName(name string) (string, error) {
+	snap := strings.Trim(snapNamePattern.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(snap) > 40 || strings.IndexFunc(snap, func(r rune) bool { return r >= 'a' && r <= 'z' }) < 0 {
+		return "", newError(errProject, nil, "'%s' is not a valid snap name, set package.name in the project manifest (up to 40 lowercase letters, digits and hyphens)", name)
+	}
+	return snap, nil
+}
+
+// yamlString quotes a YAML scalar, JSON strings are valid YAML
+func yamlString(value string) string {
+	var quoted strings.Builder
+	encoder := json.NewEncoder(&quoted)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(quoted.String(), "\n")
+}
+
+// yamlList formats a flow sequence of strings
+func yamlList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = yamlString(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// yamlBlock formats a literal block scalar indented by indent
+func yamlBlock(text string, indent string) string {
+	block := "|\n"
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line != "" {
+			block += indent + line
+		}
+		block += "\n"
+	}
+	return block
+}
+
+// writeSnap writes snap/snapcraft.yaml of the Linux application in outPath (the
+// project folder) along with its desktop entry, launcher and icon, the package
+// is built by snapcraft from outPath. Other files of the snap folder are kept.
+func (builder *Builder) writeSnap(outPath string, buildCommand string) error {
+	metadata := builder.packageMetadata()
+	var settings SnapSettings
+	if builder.manifest != nil {
+		settings = builder.manifest.Package.Snap
+	}
+	if settings.Base == "" {
+		settings.Base = "core22"
+	}
+	if settings.Confinement == "" {
+		settings.Confinement = "strict"
+	}
+	if settings.Grade == "" {
+		settings.Grade = "stable"
+		if builder.profile.debug {
+			settings.Grade = "devel"
+		}
+	}
+	if len(settings.Plugs) == 0 {
+		settings.Plugs = defaultSnapPlugs
+	}
+	if len(settings.BuildPackages) == 0 {
+		settings.BuildPackages = defaultSnapBuildPackages
+	}
+	if len(settings.StagePackages) == 0 {
+		settings.StagePackages = defaultSnapStagePackages
+	}
+	program := metadata.name
+	name, err := snapName(metadata.name)
+	if err != nil {
+		return err
+	}
+	metadata.name = name
+	if len(metadata.summary) > 78 {
+		return newError(errProject, nil, "snap summary must be less than 79 characters (first line of package description)")
+	}
+
+	// Desktop entry and icon are picked from snap/gui, the launcher from
+	// snap/local
+	snapPath := filepath.Join(outPath, "snap")
+	for _, dir := range []string{filepath.Join(snapPath, "gui"), filepath.Join(snapPath, "local")} {
+		if err := os.MkdirAll(dir, os.ModeDir|0755); err != nil {
+			return err
+		}
+	}
+	launcher := metadata.name + "-launcher"
+	launcherScript := fmt.Sprintf("#!/bin/sh\ncd \"$SNAP/share/%s\" || exit 1\nexec \"$SNAP/bin/%s\" \"$@\"\n", metadata.name, metadata.name)
+	if err := ioutil.WriteFile(filepath.Join(snapPath, "local", launcher), []byte(launcherScript), 0755); err != nil {
+		return err
+	}
+	entry := metadata.desktopEntry(metadata.name, "", builder.profile.console)
+	entry = strings.Replace(entry, fmt.Sprintf("Icon=%s\n", metadata.name), fmt.Sprintf("Icon=${SNAP}/meta/gui/%s.png\n", metadata.name), 1)
+	if err := ioutil.WriteFile(filepath.Join(snapPath, "gui", metadata.name+".desktop"), []byte(entry), 0644); err != nil {
+		return err
+	}
+	if iconPath := builder.packageIcon(); iconPath != "" {
+		size := hicolorIconSizes[len(hicolorIconSizes)-1]
+		if err := resizePNG(iconPath, filepath.Join(snapPath, "gui", metadata.name+".png"), size); err != nil {
+			return fmt.Errorf("failed to generate icon: %s", err)
+		}
+	}
+
+	build := []string{
+		buildCommand,
+		fmt.Sprintf("install -Dm755 %s $SNAPCRAFT_PART_INSTALL/bin/%s", program, metadata.name),
+		fmt.Sprintf("install -Dm755 $SNAPCRAFT_PROJECT_DIR/snap/local/%s $SNAPCRAFT_PART_INSTALL/bin/%s", launcher, launcher),
+		fmt.Sprintf("mkdir -p $SNAPCRAFT_PART_INSTALL/share/%s && cp -r %s $SNAPCRAFT_PART_INSTALL/share/%s/", metadata.name, assetsPath, metadata.name),
+	}
+	description := metadata.description
+	if description == "" {
+		description = metadata.summary
+	}
+
+	var yaml strings.Builder
+	yaml.WriteString("# Generated by tge-cli, build the snap with 'snapcraft' from the project folder\n")
+	fmt.Fprintf(&yaml, "name: %s\n", yamlString(metadata.name))
+	fmt.Fprintf(&yaml, "title: %s\n", yamlString(metadata.title))
+	fmt.Fprintf(&yaml, "version: %s\n", yamlString(metadata.version))
+	fmt.Fprintf(&yaml, "summary: %s\n", yamlString(metadata.summary))
+	fmt.Fprintf(&yaml, "description: %s", yamlBlock(description, "  "))
+	if metadata.homepage != "" {
+		fmt.Fprintf(&yaml, "website: %s\n", yamlString(metadata.homepage))
+	}
+	fmt.Fprintf(&yaml, "base: %s\n", yamlString(settings.Base))
+	fmt.Fprintf(&yaml, "grade: %s\n", yamlString(settings.Grade))
+	fmt.Fprintf(&yaml, "confinement: %s\n", yamlString(settings.Confinement))
+	yaml.WriteString("\napps:\n")
+	fmt.Fprintf(&yaml, "  %s:\n", metadata.name)
+	fmt.Fprintf(&yaml, "    command: %s\n", yamlString("bin/"+launcher))
+	fmt.Fprintf(&yaml, "    plugs: %s\n", yamlList(settings.Plugs))
+	yaml.WriteString("\nparts:\n")
+	fmt.Fprintf(&yaml, "  %s:\n", metadata.name)
+	yaml.WriteString("    plugin: nil\n")
+	yaml.WriteString("    source: .\n")
+	yaml.WriteString("    build-snaps: [\"go/latest/stable\"]\n")
+	fmt.Fprintf(&yaml, "    build-packages: %s\n", yamlList(settings.BuildPackages))
+	fmt.Fprintf(&yaml, "    stage-packages: %s\n", yamlList(settings.StagePackages))
+	fmt.Fprintf(&yaml, "    override-build: %s", yamlBlock(strings.Join(build, "\n"), "      "))
+
+	yamlPath := filepath.Join(snapPath, "snapcraft.yaml")
+	if err := ioutil.WriteFile(yamlPath, []byte(yaml.String()), 0644); err != nil {
+		return err
+	}
+	log("NOTICE", fmt.Sprintf("snapcraft.yaml written: %s", yamlPath))
+	log("NOTICE", fmt.Sprintf("build it with: cd %s && snapcraft", outPath))
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestSnapName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		err  bool
+	}{
+		{"my-game", "my-game", false},
+		{"My.Game+2", "my-game-2", false},
+		{"-game_v1.0-", "game-v1-0", false},
+		{"2048", "", true},
+		{"...", "", true},
+		{"a-very-long-game-name-that-snapcraft-would-reject", "", true},
+	}
+	for _, test := range tests {
+		name, err := snapName(test.name)
+		if (err != nil) != test.err || name != test.want {
+			t.Errorf("snapName(%q) = %q, %v, want %q, error %t", test.name, name, err, test.want, test.err)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// errNoSymbols is returned for binaries without symbol table (stripped)
+var errNoSymbols = errors.New("no symbol table")
+
+// symbolSize is the size of a symbol in a binary
+type symbolSize struct {
+	name string
+	size uint64
+}
+
+// binarySymbols returns the symbols sizes of the ELF, Mach-O, PE or wasm binary
+// at path, nil if the file is not a binary.
+func binarySymbols(path string) ([]symbolSize, error) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		return elfSymbols(f)
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		return machoSymbols(f)
+	}
+	if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		return peSymbols(f)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil || len(content) < 8 || !bytes.HasPrefix(content, wasmMagic) {
+		return nil, nil
+	}
+	return wasmSymbols(content)
+}
+
+func elfSymbols(f *elf.File) ([]symbolSize, error) {
+	syms, err := f.Symbols()
+	if err != nil {
+		return nil, errNoSymbols
+	}
+	var sizes []symbolSize
+	for _, sym := range syms {
+		if sym.Size > 0 && sym.Section != elf.SHN_UNDEF && sym.Section < elf.SHN_LORESERVE {
+			sizes = append(sizes, symbolSize{sym.Name, sym.Size})
+		}
+	}
+	return sizes, nil
+}
+
+// addressedSymbol is a symbol without size, sizes are deduced from the
+// addresses of the following symbols in the same section.
+type addressedSymbol struct {
+	name    string
+	section int
+	address uint64
+}
+
+func sizesFromAddresses(syms []addressedSymbol, sectionEnds map[int]uint64) []symbolSize {
+	sort.Slice(syms, func(i, j int) bool {
+		if syms[i].section != syms[j].section {
+			return syms[i].section < syms[j].section
+		}
+		return syms[i].address < syms[j].address
+	})
+	var sizes []symbolSize
+	for i, sym := range syms {
+		end := sectionEnds[sym.section]
+		if i+1 < len(syms) && syms[i+1].section == sym.section {
+			end = syms[i+1].address
+		}
+		if end > sym.address {
+			sizes = append(sizes, symbolSize{sym.name, end - sym.address})
+		}
+	}
+	return sizes
+}
+
+func machoSymbols(f *macho.File) ([]symbolSize, error) {
+	if f.Symtab == nil || len(f.Symtab.Syms) == 0 {
+		return nil, errNoSymbols
+	}
+	sectionEnds := map[int]uint64{}
+	for i, section := range f.Sections {
+		sectionEnds[i+1] = section.Addr + section.Size
+	}
+	var syms []addressedSymbol
+	for _, sym := range f.Symtab.Syms {
+		if sym.Sect > 0 {
+			syms = append(syms, addressedSymbol{strings.TrimPrefix(sym.Name, "_"), int(sym.Sect), sym.Value})
+		}
+	}
+	return sizesFromAddresses(syms, sectionEnds), nil
+}
+
+func peSymbols(f *pe.File) ([]symbolSize, error) {
+	if len(f.Symbols) == 0 {
+		return nil, errNoSymbols
+	}
+	sectionEnds := map[int]uint64{}
+	for i, section := range f.Sections {
+		sectionEnds[i+1] = uint64(section.VirtualSize)
+	}
+	var syms []addressedSymbol
+	for _, sym := range f.Symbols {
+		if sym.SectionNumber > 0 {
+			syms = append(syms, addressedSymbol{sym.Name, int(sym.SectionNumber), uint64(sym.Value)})
+		}
+	}
+	return sizesFromAddresses(syms, sectionEnds), nil
+}
+
+var wasmMagic = []byte{0, 'a', 's', 'm'}
+
+// wasm sections used for symbols sizes
+const (
+	wasmCustomSection = 0
+	wasmImportSection = 2
+	wasmCodeSection   = 10
+	wasmDataSection   = 11
+)
+
+// wasmSymbols returns the sizes of the functions bodies named by the "name"
+// custom section, data segments are reported as a single symbol.
+func wasmSymbols(content []byte) ([]symbolSize, error) {
+	r := bytes.NewReader(content[8:])
+	var importedFuncs uint64
+	var bodies []uint64
+	var dataSize uint64
+	names := map[uint64]string{}
+	for {
+		id, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if size > uint64(r.Len()) {
+			return nil, fmt.Errorf("truncated wasm section %d", id)
+		}
+		section := make([]byte, size)
+		if _, err = io.ReadFull(r, section); err != nil {
+			return nil, fmt.Errorf("truncated wasm section %d", id)
+		}
+		s := bytes.NewReader(section)
+		switch id {
+		case wasmImportSection:
+			if importedFuncs, err = wasmImportedFuncs(s); err != nil {
+				return nil, err
+			}
+		case wasmCodeSection:
+			count, _ := binary.ReadUvarint(s)
+			for i := uint64(0); i < count; i++ {
+				bodySize, err := binary.ReadUvarint(s)
+				if err != nil {
+					return nil, err
+				}
+				bodies = append(bodies, bodySize)
+				if err = wasmSkip(s, bodySize); err != nil {
+					return nil, err
+				}
+			}
+		case wasmDataSection:
+			dataSize = size
+		case wasmCustomSection:
+			if name, _ := wasmName(s); name == "name" {
+				wasmFunctionNames(s, names)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, errNoSymbols
+	}
+
+	sizes := []symbolSize{{"(data)", dataSize}}
+	for i, size := range bodies {
+		name, found := names[importedFuncs+uint64(i)]
+		if !found {
+			name = "(unnamed)"
+		}
+		sizes = append(sizes, symbolSize{name, size})
+	}
+	return sizes, nil
+}
+
+func wasmName(r *bytes.Reader) (string, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if size > uint64(r.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+	name := make([]byte, size)
+	if _, err = io.ReadFull(r, name); err != nil {
+		return "", err
+	}
+	return string(name), nil
+}
+
+// wasmSkip skips size bytes of r, it fails if r is shorter
+func wasmSkip(r *bytes.Reader, size uint64) error {
+	if size > uint64(r.Len()) {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := r.Seek(int64(size), io.SeekCurrent)
+	return err
+}
+
+// wasmImportedFuncs returns the number of imported functions, they come first
+// in the functions index space.
+func wasmImportedFuncs(r *bytes.Reader) (uint64, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	var funcs uint64
+	for i := uint64(0); i < count; i++ {
+		if _, err = wasmName(r); err != nil {
+			return 0, err
+		}
+		if _, err = wasmName(r); err != nil {
+			return 0, err
+		}
+		kind, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch kind {
+		case 0: // function
+			funcs++
+			_, err = binary.ReadUvarint(r)
+		case 1: // table
+			r.ReadByte()
+			err = wasmSkipLimits(r)
+		case 2: // memory
+			err = wasmSkipLimits(r)
+		case 3: // global
+			r.ReadByte()
+			_, err = r.ReadByte()
+		default:
+			err = fmt.Errorf("unknown wasm import kind %d", kind)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return funcs, nil
+}
+
+func wasmSkipLimits(r *bytes.Reader) error {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if _, err = binary.ReadUvarint(r); err == nil && flags&1 != 0 {
+		_, err = binary.ReadUvarint(r)
+	}
+	return err
+}
+
+// wasmFunctionNames reads the function names subsection of the name section
+func wasmFunctionNames(r *bytes.Reader, names map[uint64]string) {
+	for {
+		id, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return
+		}
+		if id != 1 {
+			if wasmSkip(r, size) != nil {
+				return
+			}
+			continue
+		}
+		count, _ := binary.ReadUvarint(r)
+		for i := uint64(0); i < count; i++ {
+			index, err := binary.ReadUvarint(r)
+			if err != nil {
+				return
+			}
+			name, err := wasmName(r)
+			if err != nil {
+				return
+			}
+			names[index] = wasmDemangle(name)
+		}
+		return
+	}
+}
+
+// wasmDomainPattern matches a domain, the first element of a package path
+// containing a dot
+var wasmDomainPattern = regexp.MustCompile(`^[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)+$`)
+
+// wasmDemangle restores the package path of a wasm function name, the Go linker
+// replaces '/' by '_' (ex: example.com_foo_sub.__T_.Hello). Packages without
+// domain are expected to be in the standard library.
+func wasmDemangle(name string) string {
+	if strings.HasPrefix(name, "type_.") {
+		return "type:" + name[len("type_."):]
+	}
+	// The first element of the path is a domain if it contains a dot and is
+	// followed by the rest of the path and the symbol (runtime.gc_m is not)
+	start := 0
+	if i := strings.Index(name, "_"); i > 0 && wasmDomainPattern.MatchString(name[:i]) && strings.Contains(name[i:], ".") {
+		start = i + 1
+	}
+	dot := strings.Index(name[start:], ".")
+	if dot < 0 {
+		return name
+	}
+	return strings.Replace(name[:start+dot], "_", "/", -1) + name[start+dot:]
+}
+
+// symbolPackage returns the Go package of a symbol, runtime metadata and
+// non Go symbols are grouped.
+func symbolPackage(name string) string {
+	for _, prefix := range []string{"type:", "go:", "type.", "go.itab.", "go.string.", "go.func.", "go.shape.", "go.buildid", "$"} {
+		if strings.HasPrefix(name, prefix) {
+			return "(runtime metadata)"
+		}
+	}
+	if strings.HasPrefix(name, "(") {
+		return name
+	}
+	if strings.HasPrefix(name, "_") {
+		return "(C and assembly)"
+	}
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	pkgStart := strings.LastIndex(name, "/") + 1
+	dot := strings.Index(name[pkgStart:], ".")
+	if dot <= 0 {
+		return "(C and assembly)"
+	}
+	return name[:pkgStart+dot]
+}
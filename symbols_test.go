@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// uvarint encodes value as LEB128
+func uvarint(value int) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, uint64(value))]
+}
+
+// wasmSection encodes a wasm section with its size
+func wasmSection(id byte, content ...[]byte) []byte {
+	payload := bytes.Join(content, nil)
+	return append(append([]byte{id}, uvarint(len(payload))...), payload...)
+}
+
+// wasmString encodes a wasm name
+func wasmString(name string) []byte {
+	return append(uvarint(len(name)), name...)
+}
+
+func TestWasmSymbols(t *testing.T) {
+	header := append(append([]byte{}, wasmMagic...), 1, 0, 0, 0)
+	code := wasmSection(wasmCodeSection, []byte{2}, []byte{3, 0, 0, 0}, []byte{5, 0, 0, 0, 0, 0})
+	data := wasmSection(wasmDataSection, []byte{1, 0, 0, 0})
+	functionNames := wasmSection(1, []byte{2}, []byte{0}, wasmString("main.main"), []byte{1}, wasmString("example.com_foo.Bar"))
+	names := wasmSection(wasmCustomSection, wasmString("name"), wasmSection(0, wasmString("module")), functionNames)
+
+	tests := []struct {
+		name    string
+		content []byte
+		sizes   []symbolSize
+		err     bool
+	}{
+		{
+			name:    "named functions",
+			content: bytes.Join([][]byte{header, code, data, names}, nil),
+			sizes:   []symbolSize{{"(data)", 4}, {"main.main", 3}, {"example.com/foo.Bar", 5}},
+		},
+		{
+			name:    "no name section",
+			content: bytes.Join([][]byte{header, code, data}, nil),
+			err:     true,
+		},
+		{
+			name:    "truncated section",
+			content: append(append([]byte{}, header...), wasmCodeSection, 0xff, 0xff, 0x03, 1),
+			err:     true,
+		},
+		{
+			name:    "truncated body",
+			content: bytes.Join([][]byte{header, wasmSection(wasmCodeSection, []byte{1}, []byte{9, 0})}, nil),
+			err:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sizes, err := wasmSymbols(test.content)
+			if (err != nil) != test.err {
+				t.Fatalf("wasmSymbols() error = %v, want error %t", err, test.err)
+			}
+			if !reflect.DeepEqual(sizes, test.sizes) {
+				t.Errorf("wasmSymbols() = %v, want %v", sizes, test.sizes)
+			}
+		})
+	}
+}
+
+func TestWasmDemangle(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"main.main", "main.main"},
+		{"runtime.gcStart", "runtime.gcStart"},
+		{"runtime.gc_m", "runtime.gc_m"},
+		{"internal_abi.FuncPCABI0", "internal/abi.FuncPCABI0"},
+		{"example.com_foo_sub.__T_.Hello", "example.com/foo/sub.__T_.Hello"},
+		{"github.com_thommil_tge-app.(*App).Render", "github.com/thommil/tge-app.(*App).Render"},
+		{"gopkg.in_yaml.v3.Unmarshal", "gopkg.in/yaml.v3.Unmarshal"},
+		{"my.company.internal_pkg.Run", "my.company.internal/pkg.Run"},
+		{"type_.eq.[2]string", "type:eq.[2]string"},
+		{"wasm_pc_f_loop", "wasm_pc_f_loop"},
+	}
+	for _, test := range tests {
+		if got := wasmDemangle(test.name); got != test.want {
+			t.Errorf("wasmDemangle(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSymbolPackage(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"main.main", "main"},
+		{"runtime.gcStart", "runtime"},
+		{"example.com/foo/sub.(*T).Hello", "example.com/foo/sub"},
+		{"example.com/foo.Map[go.shape.int]", "example.com/foo"},
+		{"type:.eq.[2]string", "(runtime metadata)"},
+		{"go:buildinfo", "(runtime metadata)"},
+		{"(data)", "(data)"},
+		{"_cgo_init", "(C and assembly)"},
+		{"wasm_pc_f_loop", "(C and assembly)"},
+	}
+	for _, test := range tests {
+		if got := symbolPackage(test.name); got != test.want {
+			t.Errorf("symbolPackage(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
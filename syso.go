@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Windows resource types
+const (
+	rtIcon      = 3
+	rtGroupIcon = 14
+	rtVersion   = 16
+	rtManifest  = 24
+)
+
+// resourceLanguage is the language of resources (en-US)
+const resourceLanguage = 0x0409
+
+// coffMachines maps GOARCH to the COFF machine and the relocation type of
+// 32 bits image relative addresses (ADDR32NB)
+var coffMachines = map[string]struct {
+	machine    uint16
+	relocation uint16
+}{
+	"386":   {0x14c, 0x7},
+	"amd64": {0x8664, 0x3},
+	"arm":   {0x1c4, 0x2},
+	"arm64": {0xaa64, 0x2},
+}
+
+// resource is a Windows resource of a .syso file
+type resource struct {
+	resourceType uint32
+	id           uint32
+	data         []byte
+}
+
+// writeSyso writes resources in a COFF object (.syso) with a single .rsrc
+// section, linked by go build when found in the package folder.
+func writeSyso(path string, arch string, resources []resource) error {
+	machine, found := coffMachines[arch]
+	if !found {
+		return newError(errUsage, nil, "unsupported architecture '%s' for Windows resources", arch)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].resourceType != resources[j].resourceType {
+			return resources[i].resourceType < resources[j].resourceType
+		}
+		return resources[i].id < resources[j].id
+	})
+	var types []uint32
+	byType := map[uint32][]resource{}
+	for _, r := range resources {
+		if len(byType[r.resourceType]) == 0 {
+			types = append(types, r.resourceType)
+		}
+		byType[r.resourceType] = append(byType[r.resourceType], r)
+	}
+
+	// Layout of the resource tree: root, type, id and language directories,
+	// then data entries and data
+	const dirSize, entrySize, dataEntrySize = 16, 8, 16
+	offset := dirSize + entrySize*len(types)
+	typeOffsets := map[uint32]int{}
+	for _, t := range types {
+		typeOffsets[t] = offset
+		offset += dirSize + entrySize*len(byType[t])
+	}
+	languageOffsets := make([]int, len(resources))
+	for i := range resources {
+		languageOffsets[i] = offset
+		offset += dirSize + entrySize
+	}
+	dataEntryOffsets := make([]int, len(resources))
+	for i := range resources {
+		dataEntryOffsets[i] = offset
+		offset += dataEntrySize
+	}
+	dataOffsets := make([]int, len(resources))
+	for i, r := range resources {
+		offset = (offset + 7) &^ 7
+		dataOffsets[i] = offset
+		offset += len(r.data)
+	}
+
+	var rsrc bytes.Buffer
+	write := func(values ...interface{}) {
+		writeLittleEndian(&rsrc, values...)
+	}
+	directory := func(entries int) {
+		write(uint32(0), uint32(0), uint16(0), uint16(0), uint16(0), uint16(entries))
+	}
+	directory(len(types))
+	for _, t := range types {
+		write(t, uint32(0x80000000|typeOffsets[t]))
+	}
+	index := 0
+	for _, t := range types {
+		directory(len(byType[t]))
+		for _, r := range byType[t] {
+			write(r.id, uint32(0x80000000|languageOffsets[index]))
+			index++
+		}
+	}
+	for i := range resources {
+		directory(1)
+		write(uint32(resourceLanguage), uint32(dataEntryOffsets[i]))
+	}
+	for i, r := range resources {
+		// OffsetToData is relocated to the address of the section
+		write(uint32(dataOffsets[i]), uint32(len(r.data)), uint32(0), uint32(0))
+	}
+	for i, r := range resources {
+		rsrc.Write(make([]byte, dataOffsets[i]-rsrc.Len()))
+		rsrc.Write(r.data)
+	}
+
+	// COFF object: header, .rsrc section header, raw data, relocations and
+	// symbol table with the section symbol
+	const headerSize, sectionSize, relocationSize = 20, 40, 10
+	dataPointer := headerSize + sectionSize
+	relocationsPointer := dataPointer + rsrc.Len()
+	symbolsPointer := relocationsPointer + relocationSize*len(resources)
+	characteristics := uint16(0x0004)
+	if arch == "386" || arch == "arm" {
+		characteristics |= 0x0100
+	}
+
+	var syso bytes.Buffer
+	write = func(values ...interface{}) {
+		writeLittleEndian(&syso, values...)
+	}
+	write(machine.machine, uint16(1), uint32(0), uint32(symbolsPointer), uint32(1), uint16(0), characteristics)
+	write([8]byte{'.', 'r', 's', 'r', 'c'}, uint32(0), uint32(0), uint32(rsrc.Len()), uint32(dataPointer),
+		uint32(relocationsPointer), uint32(0), uint16(len(resources)), uint16(0), uint32(0x40000040))
+	syso.Write(rsrc.Bytes())
+	for i := range resources {
+		write(uint32(dataEntryOffsets[i]), uint32(0), machine.relocation)
+	}
+	write([8]byte{'.', 'r', 's', 'r', 'c'}, uint32(0), int16(1), uint16(0), uint8(3), uint8(0))
+	write(uint32(4))
+	return ioutil.WriteFile(path, syso.Bytes(), 0644)
+}
+
+// writeLittleEndian writes fixed size values in little endian order
+func writeLittleEndian(buf *bytes.Buffer, values ...interface{}) {
+	for _, value := range values {
+		binary.Write(buf, binary.LittleEndian, value)
+	}
+}
+
+// iconResources returns the icon images and the icon group of an ICO file,
+// images IDs start at 1
+func iconResources(ico []byte) ([]resource, error) {
+	var header struct {
+		Reserved, Type, Count uint16
+	}
+	reader := bytes.NewReader(ico)
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil || header.Type != 1 || header.Count == 0 {
+		return nil, fmt.Errorf("invalid icon file")
+	}
+	var group bytes.Buffer
+	binary.Write(&group, binary.LittleEndian, header)
+	var resources []resource
+	for i := 0; i < int(header.Count); i++ {
+		var entry struct {
+			Width, Height, Colors, Reserved uint8
+			Planes, BitCount                uint16
+			Size, Offset                    uint32
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &entry); err != nil {
+			return nil, fmt.Errorf("invalid icon file")
+		}
+		if int(entry.Offset)+int(entry.Size) > len(ico) {
+			return nil, fmt.Errorf("invalid icon file")
+		}
+		id := uint16(i + 1)
+		writeLittleEndian(&group, entry.Width, entry.Height, entry.Colors, entry.Reserved, entry.Planes, entry.BitCount, entry.Size, id)
+		resources = append(resources, resource{rtIcon, uint32(id), ico[entry.Offset : entry.Offset+entry.Size]})
+	}
+	return append(resources, resource{rtGroupIcon, 1, group.Bytes()}), nil
+}
+
+// writeICO encodes PNG images of the given sizes in an ICO file
+func writeICO(images map[int][]byte) []byte {
+	sizes := make([]int, 0, len(images))
+	for size := range images {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	var ico bytes.Buffer
+	binary.Write(&ico, binary.LittleEndian, []uint16{0, 1, uint16(len(sizes))})
+	offset := 6 + 16*len(sizes)
+	for _, size := range sizes {
+		// 256 pixels is stored as 0
+		writeLittleEndian(&ico, uint8(size), uint8(size), uint8(0), uint8(0), uint16(1), uint16(32), uint32(len(images[size])), uint32(offset))
+		offset += len(images[size])
+	}
+	for _, size := range sizes {
+		ico.Write(images[size])
+	}
+	return ico.Bytes()
+}
+
+// parseFileVersion parses a version (1.2.3.4) as the 4 numbers of Windows file
+// versions, missing or invalid numbers are 0
+func parseFileVersion(version string) [4]uint16 {
+	var numbers [4]uint16
+	fields := strings.FieldsFunc(strings.TrimPrefix(version, "v"), func(r rune) bool { return r == '.' || r == '-' || r == '+' })
+	for i := 0; i < len(fields) && i < len(numbers); i++ {
+		n, _ := strconv.ParseUint(fields[i], 10, 16)
+		numbers[i] = uint16(n)
+	}
+	return numbers
+}
+
+// versionBlock encodes a block of VS_VERSIONINFO, children are aligned on 32
+// bits
+func versionBlock(key string, textType bool, value []byte, valueLength int, children ...[]byte) []byte {
+	var block bytes.Buffer
+	blockType := uint16(0)
+	if textType {
+		blockType = 1
+	}
+	binary.Write(&block, binary.LittleEndian, []uint16{0, uint16(valueLength), blockType})
+	block.Write(utf16String(key))
+	pad := func() {
+		block.Write(make([]byte, (4-block.Len()%4)%4))
+	}
+	pad()
+	block.Write(value)
+	for _, child := range children {
+		pad()
+		block.Write(child)
+	}
+	content := block.Bytes()
+	binary.LittleEndian.PutUint16(content, uint16(len(content)))
+	return content
+}
+
+// utf16String encodes a null terminated UTF-16 string
+func utf16String(text string) []byte {
+	var encoded bytes.Buffer
+	binary.Write(&encoded, binary.LittleEndian, append(utf16.Encode([]rune(text)), 0))
+	return encoded.Bytes()
+}
+
+// versionInfo encodes the VS_VERSIONINFO resource of an application
+func versionInfo(fileVersion [4]uint16, productVersion [4]uint16, language uint16, charset uint16, fields map[string]string) []byte {
+	var fixed bytes.Buffer
+	binary.Write(&fixed, binary.LittleEndian, []uint32{
+		0xFEEF04BD, 0x00010000,
+		uint32(fileVersion[0])<<16 | uint32(fileVersion[1]), uint32(fileVersion[2])<<16 | uint32(fileVersion[3]),
+		uint32(productVersion[0])<<16 | uint32(productVersion[1]), uint32(productVersion[2])<<16 | uint32(productVersion[3]),
+		0x3F, 0, 0x40004, 1, 0, 0, 0,
+	})
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var entries [][]byte
+	for _, key := range keys {
+		if fields[key] != "" {
+			value := utf16String(fields[key])
+			entries = append(entries, versionBlock(key, true, value, len(value)/2))
+		}
+	}
+	stringTable := versionBlock(fmt.Sprintf("%04X%04X", language, charset), true, nil, 0, entries...)
+	stringFileInfo := versionBlock("StringFileInfo", true, nil, 0, stringTable)
+	translation := make([]byte, 4)
+	binary.LittleEndian.PutUint16(translation, language)
+	binary.LittleEndian.PutUint16(translation[2:], charset)
+	varFileInfo := versionBlock("VarFileInfo", true, nil, 0, versionBlock("Translation", false, translation, len(translation)))
+	return versionBlock("VS_VERSION_INFO", false, fixed.Bytes(), fixed.Len(), stringFileInfo, varFileInfo)
+}
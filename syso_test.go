@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// readResources walks the resource tree of a .rsrc section, data offsets are
+// relative to the section
+func readResources(t *testing.T, rsrc []byte) []resource {
+	t.Helper()
+	le := binary.LittleEndian
+	entries := func(offset uint32) [][2]uint32 {
+		if int(offset)+16 > len(rsrc) {
+			t.Fatalf("resource directory at %d out of section", offset)
+		}
+		count := int(le.Uint16(rsrc[offset+12:])) + int(le.Uint16(rsrc[offset+14:]))
+		var list [][2]uint32
+		for i := 0; i < count; i++ {
+			entry := offset + 16 + uint32(i)*8
+			list = append(list, [2]uint32{le.Uint32(rsrc[entry:]), le.Uint32(rsrc[entry+4:])})
+		}
+		return list
+	}
+	var resources []resource
+	for _, typeEntry := range entries(0) {
+		for _, idEntry := range entries(typeEntry[1] &^ 0x80000000) {
+			languages := entries(idEntry[1] &^ 0x80000000)
+			if len(languages) != 1 || languages[0][0] != resourceLanguage || languages[0][1]&0x80000000 != 0 {
+				t.Fatalf("resource %d/%d languages = %v", typeEntry[0], idEntry[0], languages)
+			}
+			dataEntry := languages[0][1]
+			offset, size := le.Uint32(rsrc[dataEntry:]), le.Uint32(rsrc[dataEntry+4:])
+			if offset%8 != 0 || int(offset+size) > len(rsrc) {
+				t.Fatalf("resource %d/%d data at %d (%d bytes) is invalid", typeEntry[0], idEntry[0], offset, size)
+			}
+			resources = append(resources, resource{typeEntry[0], idEntry[0], rsrc[offset : offset+size]})
+		}
+	}
+	return resources
+}
+
+func TestWriteSyso(t *testing.T) {
+	resources := []resource{
+		{rtManifest, 1, []byte("<assembly/>")},
+		{rtIcon, 2, []byte("icon 2")},
+		{rtIcon, 1, []byte("icon 1 data")},
+		{rtGroupIcon, 1, []byte("group")},
+		{rtVersion, 1, []byte("version")},
+	}
+	sorted := []resource{resources[2], resources[1], resources[3], resources[4], resources[0]}
+	tests := []struct {
+		arch    string
+		machine uint16
+	}{
+		{"386", pe.IMAGE_FILE_MACHINE_I386},
+		{"amd64", pe.IMAGE_FILE_MACHINE_AMD64},
+		{"arm", pe.IMAGE_FILE_MACHINE_ARMNT},
+		{"arm64", pe.IMAGE_FILE_MACHINE_ARM64},
+	}
+	for _, test := range tests {
+		t.Run(test.arch, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rsrc.syso")
+			if err := writeSyso(path, test.arch, append([]resource{}, resources...)); err != nil {
+				t.Fatal(err)
+			}
+			f, err := pe.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if f.Machine != test.machine {
+				t.Errorf("machine = %#x, want %#x", f.Machine, test.machine)
+			}
+			section := f.Section(".rsrc")
+			if section == nil || len(f.Sections) != 1 {
+				t.Fatalf("sections = %v, want a single .rsrc section", f.Sections)
+			}
+			if len(section.Relocs) != len(resources) {
+				t.Errorf("relocations = %d, want %d", len(section.Relocs), len(resources))
+			}
+			if len(f.Symbols) != 1 || f.Symbols[0].Name != ".rsrc" {
+				t.Errorf("symbols = %v, want .rsrc section symbol", f.Symbols)
+			}
+			data, err := section.Data()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readResources(t, data); !reflect.DeepEqual(got, sorted) {
+				t.Errorf("resources = %q, want %q", got, sorted)
+			}
+		})
+	}
+
+	if err := writeSyso(filepath.Join(t.TempDir(), "rsrc.syso"), "wasm", resources); err == nil {
+		t.Errorf("writeSyso() with unsupported architecture succeeded")
+	}
+}
+
+// versionNode is a block of VS_VERSIONINFO
+type versionNode struct {
+	key      string
+	value    []byte
+	children []versionNode
+}
+
+// readVersionBlock decodes the block at the start of data and returns its size
+func readVersionBlock(t *testing.T, data []byte) (versionNode, int) {
+	t.Helper()
+	le := binary.LittleEndian
+	if len(data) < 6 {
+		t.Fatalf("truncated version block")
+	}
+	length, valueLength, textType := int(le.Uint16(data)), int(le.Uint16(data[2:])), le.Uint16(data[4:])
+	if length > len(data) {
+		t.Fatalf("version block length %d out of %d bytes", length, len(data))
+	}
+	var key []uint16
+	offset := 6
+	for ; le.Uint16(data[offset:]) != 0; offset += 2 {
+		key = append(key, le.Uint16(data[offset:]))
+	}
+	offset = (offset + 2 + 3) &^ 3
+	if textType == 1 {
+		valueLength *= 2
+	}
+	node := versionNode{key: string(utf16.Decode(key)), value: data[offset : offset+valueLength]}
+	offset += valueLength
+	for offset = (offset + 3) &^ 3; offset < length; offset = (offset + 3) &^ 3 {
+		child, size := readVersionBlock(t, data[offset:length])
+		node.children = append(node.children, child)
+		offset += size
+	}
+	return node, length
+}
+
+func TestVersionInfo(t *testing.T) {
+	fields := map[string]string{
+		"CompanyName":     "Me",
+		"FileDescription": "My Game",
+		"ProductVersion":  "1.2.3",
+		"Comments":        "",
+	}
+	content := versionInfo([4]uint16{1, 2, 3, 4}, [4]uint16{5, 6, 7, 8}, resourceLanguage, 0x04B0, fields)
+	root, size := readVersionBlock(t, content)
+	if size != len(content) {
+		t.Errorf("VS_VERSIONINFO length = %d, want %d", size, len(content))
+	}
+	if root.key != "VS_VERSION_INFO" || len(root.value) != 52 {
+		t.Fatalf("root block = %q with %d bytes value", root.key, len(root.value))
+	}
+	le := binary.LittleEndian
+	fixed := []uint32{le.Uint32(root.value), le.Uint32(root.value[8:]), le.Uint32(root.value[12:]), le.Uint32(root.value[16:]), le.Uint32(root.value[20:])}
+	if want := []uint32{0xFEEF04BD, 1<<16 | 2, 3<<16 | 4, 5<<16 | 6, 7<<16 | 8}; !reflect.DeepEqual(fixed, want) {
+		t.Errorf("fixed file info = %#x, want %#x", fixed, want)
+	}
+	if len(root.children) != 2 || root.children[0].key != "StringFileInfo" || root.children[1].key != "VarFileInfo" {
+		t.Fatalf("root children = %v", root.children)
+	}
+
+	tables := root.children[0].children
+	if len(tables) != 1 || tables[0].key != "040904B0" {
+		t.Fatalf("string tables = %v", tables)
+	}
+	values := map[string]string{}
+	for _, entry := range tables[0].children {
+		value := make([]uint16, len(entry.value)/2)
+		for i := range value {
+			value[i] = le.Uint16(entry.value[2*i:])
+		}
+		values[entry.key] = string(utf16.Decode(value[:len(value)-1]))
+	}
+	delete(fields, "Comments")
+	if !reflect.DeepEqual(values, fields) {
+		t.Errorf("string values = %q, want %q", values, fields)
+	}
+
+	translations := root.children[1].children
+	if len(translations) != 1 || translations[0].key != "Translation" || !bytes.Equal(translations[0].value, []byte{0x09, 0x04, 0xB0, 0x04}) {
+		t.Errorf("translations = %v", translations)
+	}
+}
+
+func TestIconResources(t *testing.T) {
+	ico := writeICO(map[int][]byte{16: []byte("png 16"), 256: []byte("png 256")})
+	tests := []struct {
+		name  string
+		ico   []byte
+		icons [][]byte
+		err   bool
+	}{
+		{name: "generated icon", ico: ico, icons: [][]byte{[]byte("png 16"), []byte("png 256")}},
+		{name: "empty", ico: nil, err: true},
+		{name: "no images", ico: []byte{0, 0, 1, 0, 0, 0}, err: true},
+		{name: "truncated image", ico: ico[:len(ico)-1], err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resources, err := iconResources(test.ico)
+			if (err != nil) != test.err {
+				t.Fatalf("iconResources() error = %v, want error %t", err, test.err)
+			}
+			if test.err {
+				return
+			}
+			if len(resources) != len(test.icons)+1 {
+				t.Fatalf("resources = %d, want %d icons and a group", len(resources), len(test.icons))
+			}
+			for i, icon := range test.icons {
+				if r := resources[i]; r.resourceType != rtIcon || r.id != uint32(i+1) || !bytes.Equal(r.data, icon) {
+					t.Errorf("icon %d = %d/%d %q, want %d/%d %q", i, r.resourceType, r.id, r.data, rtIcon, i+1, icon)
+				}
+			}
+			group := resources[len(resources)-1]
+			if group.resourceType != rtGroupIcon || len(group.data) != 6+14*len(test.icons) {
+				t.Errorf("group = %d with %d bytes, want %d with %d bytes", group.resourceType, len(group.data), rtGroupIcon, 6+14*len(test.icons))
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+const templateSuffix = ".tmpl"
+
+// templateVars holds the values available in project templates, files with the
+// .tmpl suffix and file names containing {{ }} markers are rendered with them.
+type templateVars struct {
+	PackageName string
+	AppName     string
+	BundleID    string
+	Author      string
+	Year        int
+	TGEVersion  string
+}
+
+// templateVars computes the variables used to render templates, missing values
+// are derived from the package name.
+func (builder *Builder) templateVars() templateVars {
+	vars := templateVars{
+		PackageName: builder.packageName,
+		AppName:     builder.appName,
+		BundleID:    builder.bundleID,
+		Author:      builder.author,
+		Year:        time.Now().Year(),
+		TGEVersion:  builder.tgeProjectVersion,
+	}
+
+	if vars.AppName == "" {
+		vars.AppName = filepath.Base(vars.PackageName)
+	}
+	if vars.BundleID == "" {
+		vars.BundleID = deriveBundleID(vars.PackageName, vars.AppName)
+	}
+	if vars.Author == "" {
+		vars.Author = defaultAuthor()
+	}
+
+	return vars
+}
+
+// deriveBundleID builds a reverse domain identifier from a package name, parts
+// only contain lowercase letters and digits and start with a letter so that it
+// is valid as iOS and macOS bundle ID, Android package name and Flatpak app ID:
+//
+//	github.com/me/my-app -> com.github.me.myapp
+//	my-app               -> com.example.myapp
+//	example.com/2048     -> com.example.a2048
+func deriveBundleID(packageName string, appName string) string {
+	tokens := strings.Split(packageName, "/")
+	var parts []string
+	if len(tokens) > 1 && strings.Contains(tokens[0], ".") {
+		domain := strings.Split(tokens[0], ".")
+		for i := len(domain) - 1; i >= 0; i-- {
+			parts = append(parts, domain[i])
+		}
+		parts = append(parts, tokens[1:len(tokens)-1]...)
+	} else {
+		parts = []string{"com", "example"}
+	}
+	parts = append(parts, appName)
+
+	var ids []string
+	for _, part := range parts {
+		if id := bundleIDPart(part); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return strings.Join(ids, ".")
+}
+
+func bundleIDPart(part string) string {
+	var id strings.Builder
+	for _, r := range strings.ToLower(part) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			id.WriteRune(r)
+		}
+	}
+	if id.Len() > 0 && unicode.IsDigit(rune(id.String()[0])) {
+		return "a" + id.String()
+	}
+	return id.String()
+}
+
+func defaultAuthor() string {
+	if output, err := exec.Command("git", "config", "user.name").Output(); err == nil {
+		if author := strings.TrimSpace(string(output)); author != "" {
+			return author
+		}
+	}
+	if author := os.Getenv("USER"); author != "" {
+		return author
+	}
+	return os.Getenv("USERNAME")
+}
+
+// renderReport lists the paths touched while rendering a template directory,
+// paths are relative to the destination.
+type renderReport struct {
+	created     []string
+	overwritten []string
+	conflicts   []string
+	// backupPath is the folder of the overwritten files, if saved
+	backupPath string
+}
+
+// renderTemplateDir copies srcPath into dstPath, rendering .tmpl files and
+// file names containing variables. Existing files are reported as conflicts
+// and left untouched unless force is set.
+func renderTemplateDir(srcPath string, dstPath string, vars templateVars, force bool) (*renderReport, error) {
+	report := &renderReport{}
+	err := filepath.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcPath, p)
+		if err != nil {
+			return err
+		}
+		if relPath, err = renderPath(relPath, vars); err != nil {
+			return err
+		}
+		relPath = strings.TrimSuffix(relPath, templateSuffix)
+		target := filepath.Join(dstPath, relPath)
+
+		existingInfo, statErr := os.Stat(target)
+		if info.IsDir() {
+			if statErr == nil {
+				if existingInfo.IsDir() {
+					return nil
+				}
+				report.conflicts = append(report.conflicts, relPath)
+				return filepath.SkipDir
+			}
+			report.created = append(report.created, relPath)
+			return os.MkdirAll(target, os.ModeDir|0755)
+		}
+
+		if statErr == nil {
+			if !force || existingInfo.IsDir() {
+				report.conflicts = append(report.conflicts, relPath)
+				return nil
+			}
+			report.overwritten = append(report.overwritten, relPath)
+		} else {
+			report.created = append(report.created, relPath)
+		}
+
+		if strings.HasSuffix(p, templateSuffix) {
+			return renderTemplateFile(p, target, info.Mode(), vars)
+		}
+		return copyFile(p, target, info.Mode())
+	})
+	return report, err
+}
+
+// renderPath renders each element of a relative path containing {{ }} markers.
+func renderPath(relPath string, vars templateVars) (string, error) {
+	if !strings.Contains(relPath, "{{") {
+		return relPath, nil
+	}
+	tokens := strings.Split(relPath, string(filepath.Separator))
+	for i, token := range tokens {
+		if !strings.Contains(token, "{{") {
+			continue
+		}
+		tpl, err := template.New(token).Parse(token)
+		if err != nil {
+			return "", fmt.Errorf("invalid template name '%s': %s", relPath, err)
+		}
+		var buf bytes.Buffer
+		if err = tpl.Execute(&buf, vars); err != nil {
+			return "", fmt.Errorf("failed to render name '%s': %s", relPath, err)
+		}
+		tokens[i] = buf.String()
+	}
+	return filepath.Join(tokens...), nil
+}
+
+func renderTemplateFile(srcPath string, dstPath string, mode os.FileMode, vars templateVars) error {
+	content, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		return err
+	}
+	tpl, err := template.New(filepath.Base(srcPath)).Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("invalid template '%s': %s", srcPath, err)
+	}
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, vars); err != nil {
+		return fmt.Errorf("failed to render '%s': %s", srcPath, err)
+	}
+	return ioutil.WriteFile(dstPath, buf.Bytes(), mode.Perm()|0200)
+}
+
+func copyFile(srcPath string, dstPath string, mode os.FileMode) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0200)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
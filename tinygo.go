@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Compilers of the browser target
+const (
+	goCompiler     = "go"
+	tinygoCompiler = "tinygo"
+)
+
+var browserCompilers = []string{goCompiler, tinygoCompiler}
+
+// wasmSizesFile records the size of the last main.wasm by profile and
+// compiler, in the workspace .tge folder.
+const wasmSizesFile = "wasm-sizes.json"
+
+// lookupTinyGo returns the path of the tinygo binary, TinyGo is never
+// installed by tge-cli.
+func (builder *Builder) lookupTinyGo() (string, error) {
+	if tinygobin := builder.config.get("tools.tinygo"); tinygobin != "" {
+		if _, err := os.Stat(tinygobin); err != nil {
+			return "", newError(errToolchain, nil, "tinygo not found in %s (tools.tinygo)", tinygobin)
+		}
+		return tinygobin, nil
+	}
+	tinygobin, err := exec.LookPath("tinygo")
+	if err != nil {
+		return "", newError(errToolchain, nil, "tinygo not found in PATH, install it from https://tinygo.org/getting-started/install/ or set tools.tinygo")
+	}
+	return tinygobin, nil
+}
+
+// tinygoRoot returns the TinyGo installation folder
+func (builder *Builder) tinygoRoot(tinygobin string) (string, error) {
+	cmd := exec.Command(tinygobin, "env", "TINYGOROOT")
+	cmd.Env = builder.environ()
+	output, err := cmd.Output()
+	if err != nil {
+		return "", newError(errToolchain, err, "'tinygo env' failed")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// tinygoBuildParams returns the tinygo build parameters equivalent to the go
+// build ones, optimize and strip profile settings are mapped to -opt and
+// -no-debug.
+func (builder *Builder) tinygoBuildParams() []string {
+	injected := builder.profileBuildFlags()
+	injected.gcflags, injected.ldflags = nil, nil
+	flags := builder.mergeBuildFlags(injected)
+
+	params := []string{"build", "-target=wasm"}
+	if builder.verbose {
+		params = append(params, "-x")
+	}
+	if !builder.profile.optimize {
+		params = append(params, "-opt=1")
+	}
+	if builder.profile.strip {
+		params = append(params, "-no-debug")
+	}
+	if len(flags.tags) > 0 {
+		params = append(params, fmt.Sprintf("-tags=%s", strings.Join(flags.tags, " ")))
+	}
+	if len(flags.ldflags) > 0 {
+		params = append(params, fmt.Sprintf("-ldflags=%s", strings.Join(flags.ldflags, " ")))
+	}
+	if len(flags.gcflags) > 0 {
+		log("WARNING", fmt.Sprintf("gcflags are not supported by TinyGo, ignored: %s", strings.Join(flags.gcflags, " ")))
+	}
+	return append(params, flags.args...)
+}
+
+// reportWasmSize logs the size of main.wasm along with the size of the last
+// build of the same profile with the other compiler.
+func (builder *Builder) reportWasmSize(compiler string) {
+	info, err := os.Stat(filepath.Join(builder.distPath, wasmFile))
+	if err != nil {
+		return
+	}
+	sizesPath := filepath.Join(builder.packagePath, tgeLocalPath, wasmSizesFile)
+	sizes := map[string]int64{}
+	if content, found := readOptionalFile(sizesPath); found {
+		json.Unmarshal(content, &sizes)
+	}
+	sizes[builder.profile.name+"/"+compiler] = info.Size()
+
+	msg := fmt.Sprintf("%s size with %s: %s", wasmFile, compiler, formatSize(info.Size()))
+	for _, other := range browserCompilers {
+		if size := sizes[builder.profile.name+"/"+other]; other != compiler && size > 0 {
+			msg += fmt.Sprintf(" (%s with %s, %+.0f%%)", formatSize(size), other, float64(info.Size()-size)*100/float64(size))
+		}
+	}
+	log("NOTICE", msg)
+
+	content, _ := json.MarshalIndent(sizes, "", "  ")
+	if err := writeFileAll(sizesPath, content); err != nil {
+		log("WARNING", fmt.Sprintf("failed to record %s size: %s", wasmFile, err))
+	}
+}
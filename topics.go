@@ -0,0 +1,172 @@
+package main
+
+import "fmt"
+
+// helpTopic is a help page of 'tge-cli help', for features configured in the
+// project manifest rather than by flags
+type helpTopic struct {
+	name    string
+	summary string
+	text    string
+}
+
+var helpTopics = []*helpTopic{
+	{
+		name:    "manifest",
+		summary: "Build settings of the project manifest",
+		text: `Profiles are defined or overridden in the project manifest (tge.json),
+unset fields are taken from the extended profile (release by default):
+    {
+        "profiles": {
+            "profiling": {
+                "extends": "release",
+                "console": true,
+                "optimize": true,
+                "strip": false,
+                "race": false,
+                "tags": ["pprof"]
+            }
+        }
+    }
+Available fields: clean, assets, universalApk, console, debug, optimize (false
+disables optimizations and inlining), strip (-s -w), race (desktop only) and tags.
+
+Build flags can also be set in the project manifest (tge.json), for all targets
+and by target (desktop, darwin, windows, linux, browser, android, ios):
+    {
+        "build": { "tags": ["prod"], "ldflags": "-s -w" },
+        "targets": {
+            "browser": { "tags": ["webgl2"], "args": ["-trimpath"] }
+        }
+    }
+Tags are merged, ldflags, gcflags and args are appended in this order: tge-cli,
+manifest, manifest target, command line.
+
+Hooks are shell commands run from the workspace root at build stages, declared
+in the project manifest for all targets ("hooks") or by target ("targets"):
+    pre-build       before compilation, once the dist folder is ready
+    post-compile    after go build (or gomobile build)
+    post-package    once the application is complete in dist
+    post-build      after the build, before the success message
+    {
+        "hooks": {
+            "pre-build": [{ "command": "go generate ./...", "timeout": "2m" }]
+        },
+        "targets": {
+            "browser": {
+                "hooks": {
+                    "post-build": [{ "command": "./deploy.sh $TGE_BUILD_DIST" }]
+                }
+            }
+        }
+    }
+Hooks are run with TGE_BUILD_HOOK, TGE_BUILD_TARGET, TGE_BUILD_PROFILE,
+TGE_BUILD_DIST, TGE_BUILD_PROGRAM, TGE_BUILD_PACKAGE_NAME, TGE_BUILD_PACKAGE_PATH
+and TGE_BUILD_TGE_VERSION environment variables. Default timeout is 10m, a
+failing hook aborts the build (exit code 4).
+
+Size budgets are set by target in the project manifest, the build fails when a
+budget is exceeded (exit code 8). Sizes are raw unless gzip is set, files are
+matched by pattern in dist/$TARGET:
+    {
+        "targets": {
+            "browser": {
+                "budget": { "total": "8MB", "assets": "5MB", "files": { "main.wasm": "2MB" } }
+            },
+            "android": { "budget": { "files": { "*.apk": "30MB" } } }
+        }
+    }`,
+	},
+	{
+		name:    "browser",
+		summary: "Browser page, PWA, single file and TinyGo builds",
+		text: `For browser target, wasm_exec.js is copied from the Go toolchain on each build
+and index.html is rendered from index.html.tmpl of the browser folder or from
+a default page (a static index.html is kept as is and browser settings are
+ignored). Page metadata is set in the project manifest, the canvas fills the
+window if no size is set:
+    {
+        "browser": { "title": "My Game", "width": 800, "height": 600, "loading": true }
+    }
+Templates can use .Title, .Width, .Height, .Loading, .WasmFile, .WasmExecFile,
+.AppName, .PackageName and .TGEVersion.
+
+With -pwa (or "pwa": true in browser settings), manifest.webmanifest and a
+service worker (sw.js) precaching all files of dist/browser are generated and
+registered in index.html, the cache is renewed when a file changes. Icons are
+generated from icon.png of the browser folder unless set in the manifest:
+    {
+        "browser": {
+            "shortName": "Game", "display": "fullscreen", "orientation": "landscape",
+            "themeColor": "#202020", "backgroundColor": "#000000",
+            "icons": [{ "src": "assets/icon-512.png", "sizes": "512x512" }]
+        }
+    }
+
+With -single-file (or "singleFile": true in browser settings), the application
+is also written as one HTML file next to dist/browser: wasm_exec.js and
+stylesheets are inlined, main.wasm and the other files are gzipped in a virtual
+file table served to fetch() at startup. A warning is logged above 20MB, set
+"singleFileMaxSize" in browser settings to change it.
+
+With -compiler tinygo, browser target is built by 'tinygo build -target=wasm'
+using TinyGo wasm_exec.js, tags, ldflags and arguments are passed as is while
+optimize and strip profile settings are mapped to -opt=1 and -no-debug. The
+size of main.wasm is compared to the last build of the other compiler.`,
+	},
+	{
+		name:    "desktop",
+		summary: "MacOS bundles, Windows resources and Linux packages",
+		text: `For darwin target, the application bundle (Contents/MacOS, Contents/Resources
+with assets and icon.icns, Info.plist) is written by tge-cli, so it can also be
+built from Linux or Windows with GOOS=darwin. icon.icns is taken from the darwin
+folder or generated from its icon.png. Info.plist uses the package metadata of
+the project manifest (id, version, title) along with MacOS settings:
+    {
+        "package": {
+            "id": "com.example.mygame", "version": "1.2.0", "title": "My Game",
+            "macos": { "minimumVersion": "10.13", "highDPI": true, "category": "public.app-category.arcade-games" }
+        }
+    }
+
+For windows target, the icon, version info and manifest resources are linked
+by tge-cli from a temporary folder, so they can also be built from Linux or
+MacOS with GOOS=windows. They are read from icon.ico (or generated from
+icon.png), versioninfo.json (goversioninfo format, overriding package metadata)
+and main.exe.manifest of the windows folder.
+
+With -package deb, the Linux desktop application is also packaged as a Debian
+package in dist/linux: the binary is installed in /usr/games (or /opt/$NAME),
+assets in /usr/share/$NAME along with a desktop entry and hicolor icons
+generated from icon.png of the linux folder. Metadata are set in the project
+manifest, the first line of the description is the summary:
+    {
+        "package": {
+            "name": "mygame", "version": "1.2.0", "title": "My Game",
+            "maintainer": "Me <me@example.com>", "homepage": "https://example.com",
+            "description": "A fancy game\nWith a longer description.",
+            "categories": ["Game", "ArcadeGame"],
+            "deb": { "depends": ["libgl1", "libasound2"], "section": "games", "install": "opt" }
+        }
+    }
+
+With -package appimage, an AppDir ($NAME.AppDir in dist/linux) is generated
+with AppRun, the desktop entry and icon at its root, the binary in usr/bin and
+assets in usr/share/$NAME. A custom desktop entry can be set in linux/$NAME.desktop,
+it is validated. The AppImage is then built if appimagetool is found in PATH
+(or set in tools.appimagetool).`,
+	},
+}
+
+func lookupHelpTopic(name string) *helpTopic {
+	for _, t := range helpTopics {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (t *helpTopic) printHelp() {
+	fmt.Printf("%s.\n\n%s\n", t.summary, t.text)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// upgradeReport lists the changes applied to a target resources folder, paths
+// are relative to the folder.
+type upgradeReport struct {
+	target    string
+	updated   []string
+	added     []string
+	removed   []string
+	merged    []string
+	kept      []string
+	conflicts []string
+	origFiles []string
+}
+
+func (builder *Builder) upgradeWorkspace(packagePath string, targets []string) ([]*upgradeReport, error) {
+	packagePath = resolvePath(builder.cwd, packagePath)
+	previousVersion := readModuleRequirement(filepath.Join(packagePath, "go.mod"), tgePackageName)
+
+	if err := builder.loadConfig(packagePath); err != nil {
+		return nil, err
+	}
+
+	if builder.tgeVersion == "" {
+		builder.tgeVersion = tgeDefaultVersion
+	}
+	if builder.tgePath != "" {
+		builder.tgeVersion = ""
+	}
+	if err := builder.openWorkspace(packagePath); err != nil {
+		return nil, err
+	}
+	log("NOTICE", fmt.Sprintf("TGE upgraded from %s to %s", previousVersion, builder.tgeProjectVersion))
+
+	labels := mergeLabels{
+		ours:   "yours",
+		base:   fmt.Sprintf("TGE %s", previousVersion),
+		theirs: fmt.Sprintf("TGE %s", builder.tgeProjectVersion),
+	}
+
+	var reports []*upgradeReport
+	for _, target := range targets {
+		resourcesPath := filepath.Join(builder.packagePath, target)
+		templatePath := filepath.Join(builder.tgeRootPath, tgeTemplatePath, target)
+		if _, err := os.Stat(resourcesPath); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+			log("WARNING", fmt.Sprintf("'%s' template not found in TGE %s, folder skipped", target, builder.tgeProjectVersion))
+			continue
+		}
+
+		basePath := builder.resourcesBasePath(target)
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			if _, err := os.Stat(builder.legacyResourcesBasePath(target)); err == nil {
+				basePath = builder.legacyResourcesBasePath(target)
+			}
+		}
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			log("WARNING", fmt.Sprintf("original '%s' template not found in %s, all local changes will be reported as conflicts", target, basePath))
+		}
+
+		theirsPath, err := ioutil.TempDir("", fmt.Sprintf("tge-upgrade-%s-", target))
+		if err != nil {
+			return reports, err
+		}
+		defer removeAll(theirsPath)
+		if _, err = renderTemplateDir(templatePath, theirsPath, builder.templateVars(), true); err != nil {
+			return reports, fmt.Errorf("failed to render '%s' template: %s", target, err)
+		}
+
+		report, err := mergeResources(target, basePath, resourcesPath, theirsPath, labels)
+		if err != nil {
+			return reports, fmt.Errorf("failed to upgrade '%s' folder: %s", target, err)
+		}
+		reports = append(reports, report)
+
+		if err = builder.saveResourcesBase(target); err != nil {
+			log("WARNING", fmt.Sprintf("failed to save '%s' template for upgrades: %s", target, err))
+		}
+	}
+
+	return reports, nil
+}
+
+// mergeResources applies the changes between basePath and theirsPath to the
+// resources folder at oursPath.
+func mergeResources(target string, basePath string, oursPath string, theirsPath string, labels mergeLabels) (*upgradeReport, error) {
+	report := &upgradeReport{target: target}
+
+	files := map[string]bool{}
+	for _, root := range []string{basePath, oursPath, theirsPath} {
+		for relPath := range listFiles(root) {
+			files[relPath] = true
+		}
+	}
+	var relPaths []string
+	for relPath := range files {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	for _, relPath := range relPaths {
+		base, hasBase := readOptionalFile(filepath.Join(basePath, relPath))
+		ours, hasOurs := readOptionalFile(filepath.Join(oursPath, relPath))
+		theirs, hasTheirs := readOptionalFile(filepath.Join(theirsPath, relPath))
+		oursFile := filepath.Join(oursPath, relPath)
+
+		switch {
+		case !hasTheirs && !hasOurs:
+			// Removed on both sides
+		case !hasTheirs:
+			if hasBase && bytes.Equal(base, ours) {
+				if err := os.Remove(oursFile); err != nil {
+					return report, err
+				}
+				report.removed = append(report.removed, relPath)
+			} else if hasBase {
+				report.kept = append(report.kept, relPath)
+			}
+		case !hasOurs:
+			if !hasBase {
+				if err := writeFileAll(oursFile, theirs); err != nil {
+					return report, err
+				}
+				report.added = append(report.added, relPath)
+			} else if !bytes.Equal(base, theirs) {
+				report.kept = append(report.kept, relPath)
+			}
+		case bytes.Equal(ours, theirs):
+			// Up to date
+		case hasBase && bytes.Equal(ours, base):
+			if err := writeFileAll(oursFile, theirs); err != nil {
+				return report, err
+			}
+			report.updated = append(report.updated, relPath)
+		case hasBase && bytes.Equal(theirs, base):
+			// Local changes only
+		case isBinary(ours) || isBinary(theirs):
+			if err := writeFileAll(oursFile+".orig", ours); err != nil {
+				return report, err
+			}
+			if err := writeFileAll(oursFile, theirs); err != nil {
+				return report, err
+			}
+			report.conflicts = append(report.conflicts, relPath)
+			report.origFiles = append(report.origFiles, relPath+".orig")
+		default:
+			merged, conflicts := merge3(base, ours, theirs, labels)
+			if err := writeFileAll(oursFile, merged); err != nil {
+				return report, err
+			}
+			if conflicts > 0 {
+				report.conflicts = append(report.conflicts, relPath)
+			} else {
+				report.merged = append(report.merged, relPath)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func readOptionalFile(path string) ([]byte, bool) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+func writeFileAll(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModeDir|0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+func (report *upgradeReport) print() {
+	fmt.Printf("\n%s/\n", report.target)
+	entries := []struct {
+		label string
+		files []string
+	}{
+		{"updated", report.updated},
+		{"added", report.added},
+		{"removed", report.removed},
+		{"merged", report.merged},
+		{"kept", report.kept},
+		{"CONFLICT", report.conflicts},
+	}
+	changes := 0
+	for _, entry := range entries {
+		for _, file := range entry.files {
+			fmt.Printf("    %-10s %s\n", entry.label, file)
+			changes++
+		}
+	}
+	if changes == 0 {
+		fmt.Println("    up to date")
+	}
+	for _, file := range report.origFiles {
+		fmt.Printf("    %-10s %s (your version)\n", "saved", file)
+	}
+}
+
+var upgradeCommand = &command{
+	name:     "upgrade",
+	summary:  "Update TGE and refresh target resources folders",
+	synopsis: []string{"[flags] [packagePath]"},
+	help: `The TGE dependency of the project in packagePath (default to current directory)
+is bumped, then each target resources folder (android, ios, browser, darwin,
+windows, linux) is merged with the new TGE template using the template originally
+copied in the project as base (stored in .tge-templates, commit it with the
+project):
+    updated     unchanged locally, replaced by new template
+    added       new in template
+    removed     removed from template and unchanged locally
+    merged      changes from both sides merged
+    kept        changed locally but removed from template, or deleted locally
+    CONFLICT    changes from both sides overlap, conflict markers are written in
+                text files, binary files are replaced and your version is saved
+                with the .orig suffix`,
+	completions: map[string][]string{
+		"target":   tgeTargets,
+		"tge-path": dirsCompletion,
+	},
+	flags: func(fs *flag.FlagSet) {
+		fs.String("tge-version", "", "TGE `version` to upgrade to (tag, commit or pseudo-version), default\nis the latest version")
+		fs.String("target", "", "comma separated list of `targets` to upgrade, default all")
+		offlineFlag(fs)
+		tgePathFlag(fs)
+		verboseFlag(fs)
+	},
+	run: doUpgrade,
+}
+
+func doUpgrade(builder Builder, fs *flag.FlagSet, args []string) {
+	packagePath := "."
+	if len(args) > 0 {
+		packagePath = args[0]
+	}
+
+	targets := tgeTargets
+	if target := flagString(fs, "target"); target != "" {
+		targets = strings.Split(target, ",")
+		if err := checkTargets(targets); err != nil {
+			fail(err)
+		}
+	}
+
+	builder.flags = flagSettings(fs, "v", "offline", "tge-path", "tge-version")
+	reports, err := builder.upgradeWorkspace(packagePath, targets)
+	conflicts := 0
+	for _, report := range reports {
+		report.print()
+		conflicts += len(report.conflicts)
+	}
+	fmt.Println()
+	if err != nil {
+		fail(err)
+	}
+
+	if conflicts > 0 {
+		log("WARNING", fmt.Sprintf("%d conflicts to resolve, look for conflict markers or .orig files", conflicts))
+		return
+	}
+	log("SUCCESS", fmt.Sprintf("Workspace upgraded to TGE %s", builder.tgeProjectVersion))
+}
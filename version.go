@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// readModuleRequirement returns the version of module required in a go.mod
+// file, a local replacement is returned as its path.
+func readModuleRequirement(goModPath string, module string) string {
+	content, err := ioutil.ReadFile(goModPath)
+	if err != nil {
+		return ""
+	}
+
+	var version, replacement string
+	parse := func(directive string, fields []string) {
+		if len(fields) < 2 || strings.Trim(fields[0], "\"") != module {
+			return
+		}
+		switch directive {
+		case "require":
+			version = fields[1]
+		case "replace":
+			if index := indexOf(fields, "=>"); index >= 0 && index+2 < len(fields) {
+				replacement = fmt.Sprintf("%s@%s", fields[index+1], fields[index+2])
+			} else if index >= 0 && index+1 < len(fields) {
+				replacement = fields[index+1]
+			}
+		}
+	}
+
+	block := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = line[:index]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case block != "" && fields[0] == ")":
+			block = ""
+		case block != "":
+			parse(block, fields)
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+		default:
+			parse(fields[0], fields[1:])
+		}
+	}
+
+	if replacement != "" {
+		return replacement
+	}
+	return version
+}
+
+func init() {
+	// Installed with 'go install github.com/thommil/tge-cli@version'
+	if info, ok := debug.ReadBuildInfo(); ok && cliVersion == "master" {
+		if version := info.Main.Version; version != "" && version != "(devel)" {
+			cliVersion = version
+		}
+	}
+}
+
+// parseSemver returns the major, minor and patch numbers of a semantic version
+// (including pseudo-versions), ok is false for branches and local paths.
+func parseSemver(version string) (numbers [3]int, ok bool) {
+	if _, err := fmt.Sscanf(version, "v%d.%d.%d", &numbers[0], &numbers[1], &numbers[2]); err != nil {
+		return numbers, false
+	}
+	return numbers, true
+}
+
+// compareSemver compares the release numbers of two semantic versions,
+// pre-release and build suffixes are ignored.
+func compareSemver(a [3]int, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// tgeCompatibility is a range of TGE versions supported by a tge-cli release
+// series, maxTGE is excluded and empty for no upper bound.
+type tgeCompatibility struct {
+	cli    string
+	minTGE string
+	maxTGE string
+}
+
+// tgeCompatibilities lists the TGE versions supported by each tge-cli series
+// (vMAJOR.MINOR), series missing from the table are not checked. Add an entry
+// when a TGE release breaks the templates or the layout used by tge-cli, for
+// instance {cli: "v0.2", minTGE: "v0.2.0", maxTGE: "v0.3.0"}.
+var tgeCompatibilities = []tgeCompatibility{}
+
+// checkTGECompatibility returns a warning if the TGE version is known to be
+// incompatible with the CLI version, empty otherwise.
+func checkTGECompatibility(cli string, tge string) string {
+	cliNumbers, cliOK := parseSemver(cli)
+	tgeNumbers, tgeOK := parseSemver(tge)
+	if !cliOK || !tgeOK || tge == tgeLocalVersion {
+		return ""
+	}
+	for _, compatibility := range tgeCompatibilities {
+		if compatibility.cli != fmt.Sprintf("v%d.%d", cliNumbers[0], cliNumbers[1]) {
+			continue
+		}
+		min, _ := parseSemver(compatibility.minTGE)
+		max, hasMax := parseSemver(compatibility.maxTGE)
+		if compareSemver(tgeNumbers, min) < 0 || (hasMax && compareSemver(tgeNumbers, max) >= 0) {
+			supported := fmt.Sprintf(">= %s", compatibility.minTGE)
+			if hasMax {
+				supported += fmt.Sprintf(", < %s", compatibility.maxTGE)
+			}
+			return fmt.Sprintf("TGE %s is known to be incompatible with tge-cli %s (supported: %s)", tge, cli, supported)
+		}
+	}
+	return ""
+}
+
+var versionCommand = &command{
+	name:     "version",
+	summary:  "Print tge-cli and project TGE versions",
+	synopsis: []string{"[packagePath]"},
+	help: `Prints the CLI version and the TGE version required by the project in
+packagePath (default to current directory).`,
+	run: doVersion,
+}
+
+func doVersion(builder Builder, fs *flag.FlagSet, args []string) {
+	packagePath := builder.cwd
+	if len(args) > 0 {
+		packagePath = args[0]
+	}
+
+	fmt.Printf("tge-cli %s\n", cliVersion)
+	goModPath := filepath.Join(packagePath, "go.mod")
+	if version := readModuleRequirement(goModPath, tgePackageName); version != "" {
+		fmt.Printf("TGE %s (project %s)\n", version, readModulePath(goModPath))
+		if warning := checkTGECompatibility(cliVersion, version); warning != "" {
+			log("WARNING", warning)
+		}
+	} else if modulePath := readModulePath(goModPath); modulePath != "" {
+		fmt.Printf("TGE not required by project %s\n", modulePath)
+	}
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
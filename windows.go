@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// windowsIconSizes are the sizes of the icons generated from windows/icon.png
+var windowsIconSizes = []int{16, 32, 48, 256}
+
+// versionInfoFile is the versioninfo.json of the windows folder, in
+// goversioninfo format, its values override the ones of package metadata
+type versionInfoFile struct {
+	FixedFileInfo struct {
+		FileVersion    fileVersion `json:"FileVersion"`
+		ProductVersion fileVersion `json:"ProductVersion"`
+	} `json:"FixedFileInfo"`
+	StringFileInfo map[string]string `json:"StringFileInfo"`
+	VarFileInfo    struct {
+		Translation struct {
+			LangID    string `json:"LangID"`
+			CharsetID string `json:"CharsetID"`
+		} `json:"Translation"`
+	} `json:"VarFileInfo"`
+}
+
+type fileVersion struct {
+	Major, Minor, Patch, Build uint16
+}
+
+func (version fileVersion) numbers() [4]uint16 {
+	return [4]uint16{version.Major, version.Minor, version.Patch, version.Build}
+}
+
+const defaultWindowsManifest = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0">
+    <assemblyIdentity type="win32" name="%s" version="%d.%d.%d.%d" processorArchitecture="*"/>
+    <compatibility xmlns="urn:schemas-microsoft-com:compatibility.v1">
+        <application>
+            <supportedOS Id="{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}"/>
+            <supportedOS Id="{1f676c76-80e1-4239-95bb-83d0f6d0da78}"/>
+            <supportedOS Id="{4a2f28e3-53b9-4441-ba9c-d69d4a4a6e38}"/>
+            <supportedOS Id="{35138b9a-5d96-4fbd-8e2d-a2440225f93a}"/>
+        </application>
+    </compatibility>
+    <application xmlns="urn:schemas-microsoft-com:asm.v3">
+        <windowsSettings>
+            <dpiAware xmlns="http://schemas.microsoft.com/SMI/2005/WindowsSettings">true</dpiAware>
+        </windowsSettings>
+    </application>
+    <dependency>
+        <dependentAssembly>
+            <assemblyIdentity type="win32" name="Microsoft.Windows.Common-Controls" version="6.0.0.0" processorArchitecture="*" publicKeyToken="6595b64144ccf1df" language="*"/>
+        </dependentAssembly>
+    </dependency>
+</assembly>
+`
+
+// windowsResources returns the icon, version info and manifest resources of
+// the Windows application, read from the windows folder or generated from the
+// package metadata.
+func (builder *Builder) windowsResources(binaryFile string) ([]resource, error) {
+	metadata := builder.packageMetadata()
+	resourcesPath := filepath.Join(builder.packagePath, builder.target)
+	var resources []resource
+
+	// Icon, icon.ico or generated from icon.png
+	ico, found := readOptionalFile(filepath.Join(resourcesPath, "icon.ico"))
+	if !found {
+		if iconPath := builder.packageIcon(); iconPath != "" {
+			images := map[int][]byte{}
+			for _, size := range windowsIconSizes {
+				icon, err := resizedPNG(iconPath, size)
+				if err != nil {
+					return nil, fmt.Errorf("failed to generate icon: %s", err)
+				}
+				images[size] = icon
+			}
+			ico, found = writeICO(images), true
+		}
+	}
+	if found {
+		icons, err := iconResources(ico)
+		if err != nil {
+			return nil, newError(errProject, err, "invalid icon.ico in '%s' folder", builder.target)
+		}
+		resources = append(resources, icons...)
+	}
+
+	// Version info
+	version := parseFileVersion(metadata.version)
+	productVersion := version
+	language, charset := uint16(resourceLanguage), uint16(0x04B0)
+	fields := map[string]string{
+		"Comments":         metadata.summary,
+		"CompanyName":      metadata.maintainer,
+		"FileDescription":  metadata.title,
+		"FileVersion":      metadata.version,
+		"InternalName":     builder.programName,
+		"LegalCopyright":   fmt.Sprintf("Copyright %d %s", builder.templateVars().Year, metadata.maintainer),
+		"OriginalFilename": binaryFile,
+		"ProductName":      metadata.title,
+		"ProductVersion":   metadata.version,
+	}
+	versionInfoPath := filepath.Join(resourcesPath, "versioninfo.json")
+	if content, found := readOptionalFile(versionInfoPath); found {
+		var file versionInfoFile
+		if err := json.Unmarshal(content, &file); err != nil {
+			return nil, newError(errProject, err, "invalid %s", versionInfoPath)
+		}
+		if numbers := file.FixedFileInfo.FileVersion.numbers(); numbers != [4]uint16{} {
+			version = numbers
+		}
+		if numbers := file.FixedFileInfo.ProductVersion.numbers(); numbers != [4]uint16{} {
+			productVersion = numbers
+		}
+		for key, value := range file.StringFileInfo {
+			if value != "" {
+				fields[key] = value
+			}
+		}
+		if id, err := strconv.ParseUint(file.VarFileInfo.Translation.LangID, 16, 16); err == nil {
+			language = uint16(id)
+		}
+		if id, err := strconv.ParseUint(file.VarFileInfo.Translation.CharsetID, 16, 16); err == nil {
+			charset = uint16(id)
+		}
+	}
+	resources = append(resources, resource{rtVersion, 1, versionInfo(version, productVersion, language, charset, fields)})
+
+	// Manifest
+	manifest, found := readOptionalFile(filepath.Join(resourcesPath, "main.exe.manifest"))
+	if !found {
+		manifest = []byte(fmt.Sprintf(defaultWindowsManifest, builder.packageID(), version[0], version[1], version[2], version[3]))
+	}
+	return append(resources, resource{rtManifest, 1, manifest}), nil
+}
+
+// windowsResourcesModule is the module holding the resources .syso, go build
+// only links .syso files found in package folders.
+const windowsResourcesModule = "tge.local/resources"
+
+// writeWindowsResources writes the resources .syso of the Windows application
+// in a module of a temporary folder, it is imported by the application using a
+// go.mod copy (-modfile) and an overlay. The project is left untouched, the
+// returned function removes the temporary folder.
+func (builder *Builder) writeWindowsResources(binaryFile string) ([]string, func(), error) {
+	resources, err := builder.windowsResources(binaryFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpPath, err := ioutil.TempDir("", "tge-windows-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpPath) }
+	if err = builder.writeResourcesModule(tmpPath, resources); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return []string{"-modfile=" + filepath.Join(tmpPath, "go.mod"), "-overlay=" + filepath.Join(tmpPath, "overlay.json")}, cleanup, nil
+}
+
+func (builder *Builder) writeResourcesModule(tmpPath string, resources []resource) error {
+	modulePath := filepath.Join(tmpPath, "resources")
+	if err := os.MkdirAll(modulePath, os.ModeDir|0755); err != nil {
+		return err
+	}
+	files := map[string]string{
+		filepath.Join(modulePath, "go.mod"):       fmt.Sprintf("module %s\n\ngo 1.16\n", windowsResourcesModule),
+		filepath.Join(modulePath, "resources.go"): "// Package resources links the Windows resources of the application\npackage resources\n",
+		filepath.Join(tmpPath, "resources.go"):    fmt.Sprintf("package main\n\nimport _ %q\n", windowsResourcesModule),
+	}
+	goMod, err := ioutil.ReadFile(builder.goModFile())
+	if err != nil {
+		return err
+	}
+	files[filepath.Join(tmpPath, "go.mod")] = fmt.Sprintf("%s\nrequire %s v0.0.0\n\nreplace %s => %s\n", goMod, windowsResourcesModule, windowsResourcesModule, modulePath)
+	if goSum, found := readOptionalFile(strings.TrimSuffix(builder.goModFile(), ".mod") + ".sum"); found {
+		files[filepath.Join(tmpPath, "go.sum")] = string(goSum)
+	}
+	overlay := map[string]map[string]string{
+		"Replace": {filepath.Join(builder.packagePath, "tge_resources_windows.go"): filepath.Join(tmpPath, "resources.go")},
+	}
+	content, _ := json.Marshal(overlay)
+	files[filepath.Join(tmpPath, "overlay.json")] = string(content)
+	for path, content := range files {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return writeSyso(filepath.Join(modulePath, fmt.Sprintf("rsrc_windows_%s.syso", goArch())), goArch(), resources)
+}